@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jonamat/hetzner-rescaler/pkg/config"
+	"github.com/jonamat/hetzner-rescaler/pkg/logger"
+	"github.com/jonamat/hetzner-rescaler/pkg/plan"
+	"github.com/jonamat/hetzner-rescaler/pkg/rescaler"
+	"github.com/jonamat/hetzner-rescaler/pkg/validator"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+	applyCmd.Flags().StringP("plan", "p", defaultPlanFile, "Path of the plan produced by the plan command")
+	applyCmd.Flags().BoolP("skip", "s", false, "Skip all user interactions")
+}
+
+/* Apply command */
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Execute a saved plan",
+	Long:  "Execute a plan produced by the plan command.\nThe plan is refused if the server state changed since it was produced.",
+	Run:   RunApply,
+}
+
+/* Run fn for apply command */
+func RunApply(cmd *cobra.Command, args []string) {
+	planFile, err := cmd.Flags().GetString("plan")
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	skip, err := cmd.Flags().GetBool("skip")
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+
+	// Get the configuration from viper
+	if err := config.CheckEnvs(); err != nil {
+		logger.Errorf("%s", err.Error())
+		cmd.Help()
+		return
+	}
+	hCloudToken := viper.GetString("HCLOUD_TOKEN")
+	rescaleOpts := rescaleOptions()
+
+	p, err := plan.Load(planFile)
+	if err != nil {
+		logger.Errorf("Error while loading the plan: %s", err.Error())
+		return
+	}
+
+	// Create hetzner Cloud API client
+	client := hcloud.NewClient(hcloud.WithToken(hCloudToken))
+
+	// Get the server the plan was produced for
+	server, _, err := client.Server.GetByID(context.Background(), p.ServerID)
+	if err != nil {
+		logger.Errorf("Error while getting server: %s", err.Error())
+		return
+	}
+	if server == nil {
+		logger.Errorf("Server not found")
+		return
+	}
+
+	// Only one instance at a time rescales the server
+	releaseLease, err := acquireLease(client, p.ServerID, "apply")
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	defer releaseLease()
+
+	// Bring the server back if a previous rescale was interrupted
+	server, err = recoverRescale(client, server, rescaleOpts)
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+
+	if err := p.Verify(server); err != nil {
+		logger.Errorf("%s", err.Error())
+		logger.Errorf("Run the plan command again")
+		return
+	}
+
+	// Check the target server type before any shutdown happens
+	serverTypeValidator, err := validator.New(client, server)
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	if err := serverTypeValidator.Tier(p.TargetTypes); err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+
+	printPlan(p)
+	if !p.HasChanges() {
+		return
+	}
+
+	fmt.Print("\n")
+	if rescaleOpts.DryRun {
+		logger.Warnf("Dry-run mode: API calls will be logged, the server will not be changed")
+	}
+
+	// Ask for confirmation if --skip is not set
+	if !skip {
+		fmt.Print("\n")
+		confirmInput := promptui.Prompt{
+			Label: "Do you want to apply this plan? (y/n)",
+		}
+		confirm, err := confirmInput.Run()
+		if err != nil {
+			logger.Errorf("%s", err.Error())
+			return
+		}
+		fmt.Printf("\n\n")
+
+		if confirm != "y" {
+			color.Red("Operation aborted")
+			return
+		}
+	}
+
+	logger.Infof("Start rescaling server...")
+	result, err := rescaler.Rescale(client, server, p.TargetTypes, rescaleOpts)
+	saveResult(sourceApply, result)
+	if err != nil {
+		logger.Errorf("Error while resizing server: %s", err.Error())
+		return
+	}
+	logger.Infof("Server successfully rescaled: %s", result)
+
+	color.New(color.FgGreen).Add(color.Bold).Println("The plan has been applied successfully")
+}
@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jonamat/hetzner-rescaler/pkg/config"
+	"github.com/jonamat/hetzner-rescaler/pkg/daemon"
+	"github.com/jonamat/hetzner-rescaler/pkg/lease"
+	"github.com/jonamat/hetzner-rescaler/pkg/logger"
+	"github.com/jonamat/hetzner-rescaler/pkg/rescaler"
+	"github.com/jonamat/hetzner-rescaler/pkg/state"
+	"github.com/jonamat/hetzner-rescaler/pkg/validator"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(boostCmd)
+	boostCmd.Flags().StringSliceP("to", "t", nil, "Server type of the boost, or a comma separated list of fallbacks (default is the top tier)")
+	boostCmd.Flags().DurationP("for", "f", 0, "Duration of the boost, eg. 2h")
+	boostCmd.Flags().BoolP("detach", "d", false, "Only hand the boost over to the running start command, fail if none holds the lease")
+	boostCmd.Flags().BoolP("skip", "s", false, "Skip all user interactions")
+}
+
+/* Boost command */
+var boostCmd = &cobra.Command{
+	Use:   "boost",
+	Short: "Upgrade the server for a while",
+	Long:  "Upgrade the server now and apply the schedule again once the boost expires.\nA running start command does not rescale the server during the boost.",
+	Run:   RunBoost,
+}
+
+/* Run fn for boost command */
+func RunBoost(cmd *cobra.Command, args []string) {
+	to, err := cmd.Flags().GetStringSlice("to")
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	duration, err := cmd.Flags().GetDuration("for")
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	detach, err := cmd.Flags().GetBool("detach")
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	skip, err := cmd.Flags().GetBool("skip")
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	if duration <= 0 {
+		logger.Errorf("The --for duration is required, eg. --for 2h")
+		return
+	}
+
+	// Get the configuration from viper
+	if err := config.CheckEnvs(); err != nil {
+		logger.Errorf("%s", err.Error())
+		cmd.Help()
+		return
+	}
+	hCloudToken := viper.GetString("HCLOUD_TOKEN")
+	serverId := viper.GetInt("SERVER_ID")
+	sched, err := config.Schedule()
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	rescaleOpts := rescaleOptions()
+
+	store, err := stateStore()
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+
+	// Create hetzner Cloud API client
+	client := hcloud.NewClient(hcloud.WithToken(hCloudToken))
+
+	// Get server
+	server, _, err := client.Server.GetByID(context.Background(), serverId)
+	if err != nil {
+		logger.Errorf("Error while getting server: %s", err.Error())
+		return
+	}
+	if server == nil {
+		logger.Errorf("Server not found")
+		return
+	}
+
+	// A running start command holding the lease applies the boost itself
+	var delegated bool
+	releaseLease, err := acquireLease(client, serverId, "boost")
+	var held *lease.HeldError
+	switch {
+	case errors.As(err, &held) && held.Holder.Command == "start":
+		// The holder reads the boost from the state only if it shares the data dir
+		if !config.LeaseSharesDataDir() {
+			logger.Errorf("%s: the boost cannot be handed over to it, run the boost command on %s", err.Error(), held.Holder.Host)
+			return
+		}
+		delegated = true
+	case err != nil:
+		logger.Errorf("%s", err.Error())
+		return
+	default:
+		defer releaseLease()
+
+		// Nothing would revert a boost left behind by this command
+		if detach {
+			logger.Errorf("No running start command holds the lease of the server, it cannot take over the boost: run boost without --detach")
+			return
+		}
+
+		// Bring the server back if a previous rescale was interrupted
+		server, err = recoverRescale(client, server, rescaleOpts)
+		if err != nil {
+			logger.Errorf("%s", err.Error())
+			return
+		}
+	}
+
+	// Boost to the top tier unless told otherwise
+	var serverNames []string
+	for _, name := range to {
+		if name = strings.TrimSpace(name); name != "" {
+			serverNames = append(serverNames, name)
+		}
+	}
+	if len(serverNames) == 0 {
+		var reason string
+		serverNames, reason, err = sched.Top.Resolve(client, server)
+		if err != nil {
+			logger.Errorf("Error while choosing the server type: %s", err.Error())
+			return
+		}
+		if reason != "" {
+			logger.Infof("Chosen server type %s", reason)
+		}
+	}
+
+	// Check the server types before any shutdown happens
+	serverTypeValidator, err := validator.New(client, server)
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	if err := serverTypeValidator.Tier(serverNames); err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	if err := validateTiers(client, server, sched); err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+
+	until := time.Now().Add(duration)
+	fmt.Printf(`The server named "%s" with ID %s, currently of type %s, will be:
+→ Upgraded to server type %s now
+→ Rescaled according to the schedule at %s
+`,
+		color.GreenString(server.Name),
+		color.GreenString(strconv.Itoa(server.ID)),
+		color.GreenString(server.ServerType.Name),
+		color.GreenString(strings.Join(serverNames, ", ")),
+		color.GreenString(until.Format("2006-01-02 15:04")),
+	)
+
+	if rescaleOpts.DryRun {
+		logger.Warnf("Dry-run mode: API calls will be logged, the server will not be changed and the boost will not be saved")
+	}
+	if delegated {
+		logger.Infof("The server is leased by %s, which will apply the boost", held.Holder)
+	}
+
+	// Ask for confirmation if --skip is not set
+	if !skip {
+		fmt.Print("\n")
+		confirmInput := promptui.Prompt{
+			Label: "Do you want to boost the server? (y/n)",
+		}
+		confirm, err := confirmInput.Run()
+		if err != nil {
+			logger.Errorf("%s", err.Error())
+			return
+		}
+		fmt.Printf("\n\n")
+
+		if confirm != "y" {
+			color.Red("Operation aborted")
+			return
+		}
+	}
+
+	// Saved before the upgrade, so the running start command leaves the server alone
+	boost := state.Boost{ServerTypes: serverNames, Start: time.Now(), Until: until, Delegated: delegated}
+	if !rescaleOpts.DryRun {
+		if _, err := store.Update(func(s *state.State) { s.Boost = &boost }); err != nil {
+			logger.Errorf("Error while saving the state: %s", err.Error())
+			return
+		}
+	}
+
+	if delegated {
+		if !rescaleOpts.DryRun {
+			logger.Infof("Boost saved, the running start command upgrades the server within a minute")
+		}
+		return
+	}
+
+	logger.Infof("Start boosting server...")
+	result, err := rescaler.Rescale(client, server, serverNames, rescaleOpts)
+	saveResult(daemon.SourceBoost, result)
+	if err != nil {
+		logger.Errorf("Error while resizing server: %s", err.Error())
+		if !rescaleOpts.DryRun {
+			if _, err := store.EndBoost(boost); err != nil {
+				logger.Errorf("Error while saving the state: %s", err.Error())
+			}
+		}
+		return
+	}
+	logger.Infof("Server successfully boosted: %s", result)
+
+	if rescaleOpts.DryRun {
+		return
+	}
+
+	/* ------------------------------ Wait & revert ----------------------------- */
+	logger.Infof("Waiting until %s, keep this command running", until.Format("15:04"))
+	time.Sleep(time.Until(until))
+
+	ended, err := store.EndBoost(boost)
+	if err != nil {
+		logger.Errorf("Error while saving the state: %s", err.Error())
+		return
+	}
+	if !ended {
+		logger.Infof("The boost has already been ended by the running start command")
+		return
+	}
+
+	// Update the server instance
+	server, _, err = client.Server.GetByID(context.Background(), serverId)
+	if err != nil {
+		logger.Errorf("Error while getting server: %s", err.Error())
+		return
+	}
+	if server == nil {
+		logger.Errorf("Server not found")
+		return
+	}
+
+	logger.Infof("Boost expired, applying the schedule again...")
+	desiredNames, reason, err := sched.Desired(time.Now()).Resolve(client, server)
+	if err != nil {
+		logger.Errorf("Error while choosing the server type: %s", err.Error())
+		return
+	}
+	if reason != "" {
+		logger.Infof("Chosen server type %s", reason)
+	}
+
+	result, err = rescaler.Rescale(client, server, desiredNames, rescaleOpts)
+	saveResult(daemon.SourceBoost, result)
+	if err != nil {
+		logger.Errorf("Error while resizing server: %s", err.Error())
+		return
+	}
+	logger.Infof("Server successfully rescaled: %s", result)
+}
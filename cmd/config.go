@@ -10,6 +10,8 @@ import (
 
 	"github.com/fatih/color"
 	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jonamat/hetzner-rescaler/pkg/logger"
+	"github.com/jonamat/hetzner-rescaler/pkg/validator"
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -78,7 +80,7 @@ func RunConfig(cmd *cobra.Command, args []string) {
 	// List of all the servers
 	servers, err := client.Server.All(context.Background())
 	if err != nil {
-		color.Red("Error: %s", err.Error())
+		logger.Errorf("%s", err.Error())
 		return
 	}
 
@@ -96,7 +98,7 @@ func RunConfig(cmd *cobra.Command, args []string) {
 
 	index, _, err := serverSelect.Run()
 	if err != nil {
-		color.Red("Error: %s", err.Error())
+		logger.Errorf("%s", err.Error())
 		return
 	}
 
@@ -118,14 +120,21 @@ func RunConfig(cmd *cobra.Command, args []string) {
 	// List of all server types
 	serverTypes, err := client.ServerType.All(context.Background())
 	if err != nil {
-		color.Red("Error: %s", err.Error())
+		logger.Errorf("%s", err.Error())
+		return
+	}
+
+	// Checks for architecture, availability, deprecation and disk size
+	serverTypeValidator, err := validator.New(client, server)
+	if err != nil {
+		logger.Errorf("%s", err.Error())
 		return
 	}
 
 	// List of all server types elegible for the selected server
 	var elegibleServerTypes []extServerType
 	for _, s := range serverTypes {
-		if s.Disk >= server.PrimaryDiskSize {
+		if serverTypeValidator.ServerType(s.Name) == nil {
 			var se = extServerType{
 				ServerType: s,
 				Memory:     int(s.Memory),
@@ -146,6 +155,11 @@ func RunConfig(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	if len(elegibleServerTypes) < 2 {
+		logger.Errorf("There are not enough server types compatible with this server")
+		return
+	}
+
 	/* ---------------------------- Base server type ---------------------------- */
 	color.Yellow("\n\n### BASE SERVER TYPE")
 
@@ -158,7 +172,7 @@ func RunConfig(cmd *cobra.Command, args []string) {
 
 	index, _, err = baseServerTypeSelect.Run()
 	if err != nil {
-		color.Red("Error: %s", err.Error())
+		logger.Errorf("%s", err.Error())
 		return
 	}
 
@@ -176,7 +190,7 @@ func RunConfig(cmd *cobra.Command, args []string) {
 
 	index, _, err = topServerTypeSelect.Run()
 	if err != nil {
-		color.Red("Error: %s", err.Error())
+		logger.Errorf("%s", err.Error())
 		return
 	}
 
@@ -184,7 +198,7 @@ func RunConfig(cmd *cobra.Command, args []string) {
 
 	/* --------------------------------- Checks --------------------------------- */
 	if topServerType.ID == baseServerType.ID {
-		color.Red("The top server type must be different from the base server type")
+		logger.Errorf("The top server type must be different from the base server type")
 		return
 	}
 
@@ -199,7 +213,7 @@ func RunConfig(cmd *cobra.Command, args []string) {
 
 	hourStart, err := startTimeInput.Run()
 	if err != nil {
-		color.Red("Error: %s", err.Error())
+		logger.Errorf("%s", err.Error())
 		return
 	}
 
@@ -214,7 +228,7 @@ func RunConfig(cmd *cobra.Command, args []string) {
 
 	hourStop, err := stopTimeInput.Run()
 	if err != nil {
-		color.Red("Error: %s", err.Error())
+		logger.Errorf("%s", err.Error())
 		return
 	}
 
@@ -240,7 +254,7 @@ func RunConfig(cmd *cobra.Command, args []string) {
 	}
 	confirm, err := confirmInput.Run()
 	if err != nil {
-		color.Red("Error: %s", err.Error())
+		logger.Errorf("%s", err.Error())
 		return
 	}
 
@@ -265,21 +279,21 @@ func RunConfig(cmd *cobra.Command, args []string) {
 
 	if _, err := os.Stat(configPath); err != nil {
 		if _, err := os.Create(configPath); err != nil {
-			color.Red("Error: %s", err.Error())
+			logger.Errorf("%s", err.Error())
 			return
 		}
 	} else {
 		if err := os.Remove(viper.ConfigFileUsed()); err != nil {
-			color.Red("Error: %s", err.Error())
+			logger.Errorf("%s", err.Error())
 			return
 		}
 	}
 
 	// Save the configuration
 	if err := viper.WriteConfig(); err != nil {
-		color.Red("Error: %s", err.Error())
+		logger.Errorf("%s", err.Error())
 		return
 	}
 
-	color.New(color.FgGreen).Add(color.Bold).Println("\n\nConfiguration saved\n")
+	color.New(color.FgGreen).Add(color.Bold).Print("\n\nConfiguration saved\n\n")
 }
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jonamat/hetzner-rescaler/pkg/config"
+	"github.com/jonamat/hetzner-rescaler/pkg/history"
+	"github.com/jonamat/hetzner-rescaler/pkg/logger"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.Flags().Int("server", 0, "Only the rescales of this server ID")
+	historyCmd.Flags().String("since", "", "Only the rescales started from this date (2006-01-02 or RFC 3339)")
+	historyCmd.Flags().String("until", "", "Only the rescales started until this date included (2006-01-02 or RFC 3339)")
+	historyCmd.Flags().StringP("format", "f", "table", "Output format: table, csv or json")
+	historyCmd.Flags().StringP("out", "o", "", "Write to this file instead of the standard output")
+}
+
+/* History command */
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show the past rescales",
+	Long:  "Show the rescale attempts recorded in DATA_DIR/history.jsonl, optionally exported as CSV or JSON",
+	Run:   RunHistory,
+}
+
+/* Run fn for history command */
+func RunHistory(cmd *cobra.Command, args []string) {
+	var filter history.Filter
+	var err error
+
+	if filter.ServerID, err = cmd.Flags().GetInt("server"); err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	since, err := cmd.Flags().GetString("since")
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	until, err := cmd.Flags().GetString("until")
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	out, err := cmd.Flags().GetString("out")
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+
+	if since != "" {
+		if filter.Since, err = parseDate(since, false); err != nil {
+			logger.Errorf("%s", err.Error())
+			return
+		}
+	}
+	if until != "" {
+		if filter.Until, err = parseDate(until, true); err != nil {
+			logger.Errorf("%s", err.Error())
+			return
+		}
+	}
+
+	dir, err := config.DataDir()
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	entries, err := history.Open(dir).Read(filter)
+	if err != nil {
+		logger.Errorf("Error while reading the history: %s", err.Error())
+		return
+	}
+
+	var w io.Writer = os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			logger.Errorf("%s", err.Error())
+			return
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch format {
+	case "json":
+		err = history.WriteJSON(w, entries)
+	case "csv":
+		err = history.WriteCSV(w, entries)
+	case "table":
+		err = writeHistoryTable(w, entries)
+	default:
+		err = fmt.Errorf("invalid format %q, expected table, csv or json", format)
+	}
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+	}
+}
+
+/* Date-only values cover the whole day when used as upper bound */
+func parseDate(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected 2006-01-02 or a RFC 3339 date", value)
+	}
+	return t, nil
+}
+
+func writeHistoryTable(w io.Writer, entries []history.Entry) error {
+	if len(entries) == 0 {
+		_, err := fmt.Fprintln(w, "No rescales recorded")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "START\tSERVER\tSOURCE\tOUTCOME\tFROM\tTO\tDOWNTIME\tERROR")
+	for _, e := range entries {
+		outcome := e.Outcome
+		if e.DryRun {
+			outcome += " (dry-run)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.Start.Local().Format("2006-01-02 15:04"),
+			strconv.Itoa(e.ServerID),
+			e.Source,
+			outcome,
+			e.FromType,
+			e.ToType,
+			e.Downtime.Round(time.Second),
+			e.Error,
+		)
+	}
+	return tw.Flush()
+}
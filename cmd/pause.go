@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/jonamat/hetzner-rescaler/pkg/logger"
+	"github.com/jonamat/hetzner-rescaler/pkg/state"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(pauseCmd)
+	pauseCmd.Flags().StringP("until", "u", "", "Resume automatically after a duration (2h), at a time (15:04) or a RFC 3339 date")
+}
+
+/* Pause command */
+var pauseCmd = &cobra.Command{
+	Use:   "pause",
+	Short: "Pause the scheduled transitions",
+	Long:  "Pause the scheduled transitions of the running timer, the server stays on its current type.\nThe pause is kept across restarts until resumed.",
+	Run:   RunPause,
+}
+
+/* Run fn for pause command */
+func RunPause(cmd *cobra.Command, args []string) {
+	untilFlag, err := cmd.Flags().GetString("until")
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+
+	var until time.Time
+	if untilFlag != "" {
+		until, err = state.ResumeTime(untilFlag, time.Now())
+		if err != nil {
+			logger.Errorf("%s", err.Error())
+			return
+		}
+	}
+
+	store, err := stateStore()
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	st, err := store.Update(func(s *state.State) {
+		s.Paused = true
+		s.PausedAt = time.Now()
+		s.ResumeAt = until
+	})
+	if err != nil {
+		logger.Errorf("Error while saving the state: %s", err.Error())
+		return
+	}
+
+	printState(st)
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jonamat/hetzner-rescaler/pkg/config"
+	"github.com/jonamat/hetzner-rescaler/pkg/logger"
+	"github.com/jonamat/hetzner-rescaler/pkg/plan"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+/* Default path of the saved plan */
+const defaultPlanFile = "hetzner-rescaler.plan.json"
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+	planCmd.Flags().StringP("out", "o", defaultPlanFile, "Path where the plan is saved")
+}
+
+/* Plan command */
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show the changes the schedule wants now",
+	Long:  "Compare the current state of the server with what the schedule wants now and save the plan.\nUse the apply command to execute it.",
+	Run:   RunPlan,
+}
+
+/* Run fn for plan command */
+func RunPlan(cmd *cobra.Command, args []string) {
+	out, err := cmd.Flags().GetString("out")
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+
+	// Get the configuration from viper
+	if err := config.CheckEnvs(); err != nil {
+		logger.Errorf("%s", err.Error())
+		cmd.Help()
+		return
+	}
+	hCloudToken := viper.GetString("HCLOUD_TOKEN")
+	serverId := viper.GetInt("SERVER_ID")
+	sched, err := config.Schedule()
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+
+	// Create hetzner Cloud API client
+	client := hcloud.NewClient(hcloud.WithToken(hCloudToken))
+
+	// Get server
+	server, _, err := client.Server.GetByID(context.Background(), serverId)
+	if err != nil {
+		logger.Errorf("Error while getting server: %s", err.Error())
+		return
+	}
+	if server == nil {
+		logger.Errorf("Server not found")
+		return
+	}
+
+	p, err := plan.Build(client, server, sched, time.Now())
+	if err != nil {
+		logger.Errorf("Error while building the plan: %s", err.Error())
+		return
+	}
+
+	printPlan(p)
+
+	if !p.HasChanges() {
+		return
+	}
+
+	if err := p.Save(out); err != nil {
+		logger.Errorf("Error while saving the plan: %s", err.Error())
+		return
+	}
+	fmt.Printf("\nPlan saved to %s, run \"hetzner-rescaler apply --plan %s\" to execute it\n", color.GreenString(out), out)
+}
+
+/* Print the diff between the current and the planned state */
+func printPlan(p *plan.Plan) {
+	fmt.Printf("The server named \"%s\" with ID %s is currently %s, of type %s\n\n",
+		color.GreenString(p.ServerName),
+		color.GreenString(strconv.Itoa(p.ServerID)),
+		color.GreenString(p.State.Status),
+		color.GreenString(p.State.ServerType),
+	)
+
+	if !p.HasChanges() {
+		logger.Infof("No changes, the server already matches the schedule")
+		return
+	}
+
+	hourlyDelta := p.PriceHourlyTo - p.PriceHourlyFrom
+	monthlyDelta := p.PriceMonthlyTo - p.PriceMonthlyFrom
+
+	if p.Reason != "" {
+		fmt.Printf("Chosen server type %s\n\n", p.Reason)
+	}
+
+	var fallbacks string
+	if len(p.TargetTypes) > 1 {
+		fallbacks = fmt.Sprintf(" (fallback: %s)", strings.Join(p.TargetTypes[1:], ", "))
+	}
+
+	fmt.Printf(`~ Server type:       %s → %s%s
+~ Expected downtime: ~%s
+~ Price:             %.4f%s/h → %.4f%s/h (%s/h, %s/month)
+`,
+		color.RedString(p.State.ServerType),
+		color.GreenString(p.TargetType()),
+		fallbacks,
+		p.ExpectedDowntime,
+		p.PriceHourlyFrom, p.Currency,
+		p.PriceHourlyTo, p.Currency,
+		colorDelta(fmt.Sprintf("%+.4f%s", hourlyDelta, p.Currency), hourlyDelta),
+		colorDelta(fmt.Sprintf("%+.2f%s", monthlyDelta, p.Currency), monthlyDelta),
+	)
+}
+
+/* Red for additional costs, green for savings */
+func colorDelta(s string, delta float64) string {
+	if delta > 0 {
+		return color.RedString(s)
+	}
+	return color.GreenString(s)
+}
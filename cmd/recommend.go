@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jonamat/hetzner-rescaler/pkg/autoscale"
+	"github.com/jonamat/hetzner-rescaler/pkg/logger"
+	"github.com/jonamat/hetzner-rescaler/pkg/pricing"
+	"github.com/jonamat/hetzner-rescaler/pkg/recommend"
+	"github.com/jonamat/hetzner-rescaler/pkg/tier"
+	"github.com/jonamat/hetzner-rescaler/pkg/validator"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(recommendCmd)
+	recommendCmd.Flags().IntP("days", "d", 14, "Days of metrics to analyze")
+	recommendCmd.Flags().Float64("busy", 40, "CPU usage in percent from which an hour counts as busy")
+	recommendCmd.Flags().Float64("target", 70, "CPU usage in percent the proposed server types should run at")
+}
+
+/* Recommend command */
+var recommendCmd = &cobra.Command{
+	Use:   "recommend",
+	Short: "Propose a schedule from the server metrics",
+	Long:  "Analyze the CPU usage of the server over the last days, find the busy hours and propose a schedule and server types with their monthly cost",
+	Run:   RunRecommend,
+}
+
+/* Run fn for recommend command */
+func RunRecommend(cmd *cobra.Command, args []string) {
+	days, err := cmd.Flags().GetInt("days")
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	busy, err := cmd.Flags().GetFloat64("busy")
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	target, err := cmd.Flags().GetFloat64("target")
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	if days < 1 || target <= 0 || target > 100 {
+		logger.Errorf("--days must be at least 1 and --target between 0 and 100")
+		return
+	}
+
+	// Only the server is needed, not the schedule
+	hCloudToken := viper.GetString("HCLOUD_TOKEN")
+	serverId := viper.GetInt("SERVER_ID")
+	if hCloudToken == "" || serverId == 0 {
+		logger.Errorf("Missing or incomplete configuration")
+		cmd.Help()
+		return
+	}
+
+	location, err := time.LoadLocation(os.Getenv("TZ"))
+	if err != nil {
+		logger.Warnf("Error while loading timezone: %s, fallback to UTC", err.Error())
+		location = time.UTC
+	}
+
+	// Create hetzner Cloud API client
+	client := hcloud.NewClient(hcloud.WithToken(hCloudToken))
+
+	// Get server
+	server, _, err := client.Server.GetByID(context.Background(), serverId)
+	if err != nil {
+		logger.Errorf("Error while getting server: %s", err.Error())
+		return
+	}
+	if server == nil {
+		logger.Errorf("Server not found")
+		return
+	}
+
+	// Hourly averages are enough to find the busy hours
+	end := time.Now()
+	series, err := autoscale.ServerSeries(client, serverId, hcloud.ServerMetricCPU, end.AddDate(0, 0, -days), end, 3600)
+	if err != nil {
+		logger.Errorf("Error while getting the metrics: %s", err.Error())
+		return
+	}
+	points := series["cpu"]
+	if len(points) == 0 {
+		logger.Errorf("No CPU metrics for the server in the last %d days", days)
+		return
+	}
+
+	profile := recommend.Analyze(points, location)
+	fmt.Printf("CPU usage of %s (%s, %d vCPU) over %d days, busy from %.0f%%:\n\n",
+		color.GreenString(server.Name), server.ServerType.Name, server.ServerType.Cores, profile.Days, busy)
+	for day := time.Monday; day <= time.Saturday+1; day++ {
+		weekday := day % 7
+		period, ok := profile.BusyPeriod(weekday, busy)
+		if !ok {
+			fmt.Printf("  %-9s  idle\n", weekday)
+			continue
+		}
+		fmt.Printf("  %-9s  busy %02d:00 - %02d:00, peak %.0f%%\n", weekday, period.Start, period.Stop%24, dayPeak(profile, weekday, period))
+	}
+	fmt.Println()
+
+	window, ok := profile.Window(busy)
+
+	// Keep the CPU type and architecture of the server
+	serverTypeValidator, err := validator.New(client, server)
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	requirements := func(usage float64) tier.Requirements {
+		return tier.Requirements{
+			MinCores:     recommend.Cores(usage, server.ServerType.Cores, target),
+			CPUType:      string(server.ServerType.CPUType),
+			Architecture: serverTypeValidator.Architecture(server.ServerType.Name),
+		}
+	}
+
+	// Without a part of the day to downgrade, a schedule is of no use
+	if !ok || window.Hours() >= 24 {
+		if !ok {
+			logger.Infof("No busy hours found, the server can stay on a single type all day")
+		} else {
+			logger.Infof("The server is busy all day, it can stay on a single type")
+		}
+		allDay := recommend.Period{Start: 0, Stop: 24}
+		fixedReq := requirements(profile.Peak(allDay, true))
+		fixed, err := recommendedType(client, server, fixedReq)
+		if err != nil {
+			logger.Errorf("Error while choosing the server type: %s", err.Error())
+			return
+		}
+		printFixedType(server, fixed, fixedReq)
+		return
+	}
+
+	topReq := requirements(profile.Peak(window, true))
+	baseReq := requirements(profile.Peak(window, false))
+	top, err := recommendedType(client, server, topReq)
+	if err != nil {
+		logger.Errorf("Error while choosing the top server type: %s", err.Error())
+		return
+	}
+	base, err := recommendedType(client, server, baseReq)
+	if err != nil {
+		logger.Errorf("Error while choosing the base server type: %s", err.Error())
+		return
+	}
+
+	if top.Name == base.Name {
+		logger.Infof("The busy and the quiet hours need the same server type")
+		printFixedType(server, top, topReq)
+		return
+	}
+
+	serverLocation := pricing.ServerLocation(server)
+	topHourly, topMonthly, currency, okTop := pricing.ServerTypePrice(top, serverLocation)
+	baseHourly, _, _, okBase := pricing.ServerTypePrice(base, serverLocation)
+
+	color.New(color.FgGreen).Add(color.Bold).Println("Proposed configuration")
+	fmt.Printf("hour_start: \"%02d:00\"\nhour_stop: \"%02d:00\"\ntop_server_name: %s # %s\nbase_server_name: %s # %s\n\n",
+		window.Start, window.Stop%24, top.Name, topReq, base.Name, baseReq)
+
+	if !okTop || !okBase {
+		logger.Warnf("Prices not available for %s, cannot estimate the monthly cost", serverLocation)
+		return
+	}
+
+	scheduled := recommend.MonthlyCost(topHourly, window.Hours(), baseHourly, topMonthly)
+	permanent := recommend.MonthlyCost(topHourly, 24, 0, topMonthly)
+	fmt.Printf("Estimated monthly cost: %s with the schedule, %s running %s permanently (%s)\n",
+		color.GreenString("%.2f %s", scheduled, currency),
+		color.YellowString("%.2f %s", permanent, currency),
+		top.Name,
+		colorDelta(fmt.Sprintf("%+.2f %s", scheduled-permanent, currency), scheduled-permanent),
+	)
+	logger.Warnf("Memory usage is not part of the Hetzner metrics, check the proposed types have enough memory")
+}
+
+/* Propose to keep the server on a single type, with its cost compared to the current type */
+func printFixedType(server *hcloud.Server, serverType *hcloud.ServerType, req tier.Requirements) {
+	color.New(color.FgGreen).Add(color.Bold).Println("Proposed configuration")
+	fmt.Printf("No schedule: keep the server on %s permanently # %s\n\n", color.GreenString(serverType.Name), req)
+
+	serverLocation := pricing.ServerLocation(server)
+	hourly, monthly, currency, ok := pricing.ServerTypePrice(serverType, serverLocation)
+	currentHourly, currentMonthly, _, okCurrent := pricing.ServerTypePrice(server.ServerType, serverLocation)
+	if !ok || !okCurrent {
+		logger.Warnf("Prices not available for %s, cannot estimate the monthly cost", serverLocation)
+		return
+	}
+
+	proposed := recommend.MonthlyCost(hourly, 24, 0, monthly)
+	current := recommend.MonthlyCost(currentHourly, 24, 0, currentMonthly)
+	fmt.Printf("Estimated monthly cost: %s on %s, %s on the current %s (%s)\n",
+		color.GreenString("%.2f %s", proposed, currency),
+		serverType.Name,
+		color.YellowString("%.2f %s", current, currency),
+		server.ServerType.Name,
+		colorDelta(fmt.Sprintf("%+.2f %s", proposed-current, currency), proposed-current),
+	)
+	logger.Warnf("Memory usage is not part of the Hetzner metrics, check the proposed type has enough memory")
+}
+
+/* Cheapest available server type matching the requirements */
+func recommendedType(client *hcloud.Client, server *hcloud.Server, req tier.Requirements) (*hcloud.ServerType, error) {
+	names, _, err := tier.Select(client, server, req)
+	if err != nil {
+		return nil, err
+	}
+	serverType, _, err := client.ServerType.GetByName(context.Background(), names[0])
+	if err != nil {
+		return nil, err
+	}
+	if serverType == nil {
+		return nil, fmt.Errorf("server type %s not found", names[0])
+	}
+	return serverType, nil
+}
+
+/* Highest hourly usage of the weekday within the period */
+func dayPeak(profile recommend.Profile, day time.Weekday, period recommend.Period) float64 {
+	var peak float64
+	for hour := period.Start; hour < period.Stop; hour++ {
+		peak = math.Max(peak, profile.Usage[day][hour%24])
+	}
+	return peak
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jonamat/hetzner-rescaler/pkg/config"
+	"github.com/jonamat/hetzner-rescaler/pkg/daemon"
+	"github.com/jonamat/hetzner-rescaler/pkg/logger"
+	"github.com/jonamat/hetzner-rescaler/pkg/schedule"
+	"github.com/spf13/viper"
+)
+
+// watchConfig applies the schedule of the config file to the daemon whenever the file changes.
+// An invalid configuration is logged and the daemon keeps the current one.
+// Viper is only read here, on the goroutine reloading it, the daemon only gets the parsed schedule.
+func watchConfig(client *hcloud.Client, d *daemon.Daemon, current schedule.Schedule) {
+	logger.Infof("Watching %s for changes", viper.ConfigFileUsed())
+
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		sched, err := config.Schedule()
+		if err != nil {
+			logger.Errorf("Invalid configuration in %s, keeping the previous one: %s", e.Name, err.Error())
+			return
+		}
+
+		changes := sched.Diff(current)
+		if len(changes) == 0 {
+			return
+		}
+
+		server, _, err := client.Server.GetByID(context.Background(), d.ServerID)
+		if err != nil {
+			logger.Errorf("Error while getting server, keeping the previous configuration: %s", err.Error())
+			return
+		}
+		if server == nil {
+			logger.Errorf("Server not found, keeping the previous configuration")
+			return
+		}
+		if err := validateTiers(client, server, sched); err != nil {
+			logger.Errorf("Invalid configuration in %s, keeping the previous one: %s", e.Name, err.Error())
+			return
+		}
+
+		if err := d.SetSchedule(sched); err != nil {
+			logger.Errorf("Error while applying the configuration, keeping the previous one: %s", err.Error())
+			return
+		}
+		current = sched
+		logger.Infof("Configuration reloaded: %s", strings.Join(changes, ", "))
+	})
+	viper.WatchConfig()
+}
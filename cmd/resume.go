@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"github.com/jonamat/hetzner-rescaler/pkg/logger"
+	"github.com/jonamat/hetzner-rescaler/pkg/state"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(resumeCmd)
+}
+
+/* Resume command */
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Resume the scheduled transitions",
+	Long:  "Resume the scheduled transitions paused with the pause command",
+	Run:   RunResume,
+}
+
+/* Run fn for resume command */
+func RunResume(cmd *cobra.Command, args []string) {
+	store, err := stateStore()
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	st, err := store.Update(func(s *state.State) {
+		s.Resume()
+	})
+	if err != nil {
+		logger.Errorf("Error while saving the state: %s", err.Error())
+		return
+	}
+
+	printState(st)
+}
@@ -1,9 +1,23 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/fatih/color"
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jonamat/hetzner-rescaler/pkg/config"
+	"github.com/jonamat/hetzner-rescaler/pkg/daemon"
+	"github.com/jonamat/hetzner-rescaler/pkg/history"
+	"github.com/jonamat/hetzner-rescaler/pkg/lease"
+	"github.com/jonamat/hetzner-rescaler/pkg/logger"
+	"github.com/jonamat/hetzner-rescaler/pkg/rescaler"
+	"github.com/jonamat/hetzner-rescaler/pkg/state"
 	"github.com/spf13/cobra"
 
 	"github.com/spf13/viper"
@@ -35,6 +49,12 @@ func init() {
 	// will be global for your application.
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.hetzner-rescaler.yaml)")
+	rootCmd.PersistentFlags().Bool("dry-run", false, "Log the API calls that would be made without changing the server")
+	viper.BindPFlag("DRY_RUN", rootCmd.PersistentFlags().Lookup("dry-run"))
+	rootCmd.PersistentFlags().String("log-format", logger.FormatText, "Format of the logs, text or json")
+	viper.BindPFlag("LOG_FORMAT", rootCmd.PersistentFlags().Lookup("log-format"))
+	rootCmd.PersistentFlags().String("log-level", "info", "Minimum level of the logs: debug, info, warn or error")
+	viper.BindPFlag("LOG_LEVEL", rootCmd.PersistentFlags().Lookup("log-level"))
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
@@ -60,7 +80,138 @@ func initConfig() {
 	viper.AutomaticEnv() // read in environment variables that match
 
 	// If a config file is found, read it in.
-	if err := viper.ReadInConfig(); err == nil {
-		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+	configErr := viper.ReadInConfig()
+
+	// The logs can be configured in the config file too
+	level, err := logger.ParseLevel(viper.GetString("LOG_LEVEL"))
+	cobra.CheckErr(err)
+	cobra.CheckErr(logger.Configure(viper.GetString("LOG_FORMAT"), level))
+
+	if configErr == nil {
+		logger.Infof("Using config file: %s", viper.ConfigFileUsed())
+	}
+}
+
+// rescaleOptions builds the rescaler options from the configuration.
+func rescaleOptions() rescaler.Options {
+	opts := rescaler.Options{
+		DryRun: viper.GetBool("DRY_RUN"),
+		Preflight: rescaler.PreflightOptions{
+			RefuseProtection:   config.List("PREFLIGHT_REFUSE_PROTECTION"),
+			IgnoreBackupWindow: viper.GetBool("PREFLIGHT_IGNORE_BACKUP_WINDOW"),
+		},
+	}
+
+	// Checkpoints let an interrupted rescale be recovered on the next run
+	dir, err := config.DataDir()
+	if err != nil {
+		logger.Warnf("Rescale checkpoints disabled: %s", err.Error())
+		return opts
+	}
+	opts.CheckpointDir = dir
+	return opts
+}
+
+// acquireLease takes the lease of the server for the command and keeps it renewed, the returned func releases it.
+func acquireLease(client *hcloud.Client, serverID int, command string) (func(), error) {
+	l, ttl, err := config.Lease(client, serverID, command)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil {
+		return func() {}, nil
+	}
+	if err := l.Acquire(ttl); err != nil {
+		return nil, err
+	}
+
+	return lease.Keep(l, ttl, func(err error) {
+		var held *lease.HeldError
+		if errors.As(err, &held) {
+			// Another instance took over, stop before both act on the server
+			logger.Errorf("Lease lost: %s", err.Error())
+			os.Exit(1)
+		}
+		logger.Warnf("Error while renewing the lease: %s", err.Error())
+	}), nil
+}
+
+// recoverRescale finishes or rolls back a rescale interrupted by a crash, returning the updated server.
+func recoverRescale(client *hcloud.Client, server *hcloud.Server, opts rescaler.Options) (*hcloud.Server, error) {
+	result, err := rescaler.Recover(client, server, opts)
+	if result == nil {
+		return server, err
+	}
+	saveResult(daemon.SourceRecovery, result)
+	if err != nil {
+		return nil, fmt.Errorf("error while recovering the interrupted rescale: %s", err.Error())
+	}
+
+	server, _, err = client.Server.GetByID(context.Background(), server.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting server: %s", err.Error())
+	}
+	if server == nil {
+		return nil, fmt.Errorf("server not found")
+	}
+	return server, nil
+}
+
+// Sources of the rescales run by the commands, the daemon has its own
+const (
+	sourceTry   = "try"
+	sourceApply = "apply"
+)
+
+// saveResult appends the rescale attempt to the history, marked if simulated, and persists the last real rescale in the data dir.
+func saveResult(source string, result *rescaler.Result) {
+	dir, err := config.DataDir()
+	if err != nil {
+		logger.Errorf("Error while saving the rescale result: %s", err.Error())
+		return
+	}
+	saveResultIn(dir, source, result)
+}
+
+// saveResultIn is saveResult with the data dir already resolved, for the daemon which must not read viper while the config file is watched.
+func saveResultIn(dir string, source string, result *rescaler.Result) {
+	if err := history.Open(dir).Append(history.Entry{Source: source, Result: *result}); err != nil {
+		logger.Errorf("Error while saving the rescale history: %s", err.Error())
+	}
+
+	// Nothing was done on the server, keep the previous result
+	if len(result.Phases) == 0 || result.DryRun {
+		return
+	}
+	if err := result.Save(filepath.Join(dir, "last-rescale.json")); err != nil {
+		logger.Errorf("Error while saving the rescale result: %s", err.Error())
+	}
+}
+
+// stateStore opens the pause and skip-next state shared with the running daemon.
+func stateStore() (*state.Store, error) {
+	dir, err := config.DataDir()
+	if err != nil {
+		return nil, err
+	}
+	return state.Open(dir), nil
+}
+
+// printState describes the pause and skip-next state.
+func printState(st state.State) {
+	now := time.Now()
+	switch {
+	case st.IsPaused(now) && !st.ResumeAt.IsZero():
+		fmt.Printf("The scheduling is %s until %s.\n", color.YellowString("paused"), color.YellowString(st.ResumeAt.Format("2006-01-02 15:04")))
+	case st.IsPaused(now):
+		fmt.Printf("The scheduling is %s until resumed.\n", color.YellowString("paused"))
+	default:
+		fmt.Printf("The scheduling is %s.\n", color.GreenString("active"))
+	}
+	if st.Boosted(now) {
+		fmt.Printf("The server is %s to %s until %s.\n", color.YellowString("boosted"), color.YellowString(strings.Join(st.Boost.ServerTypes, ", ")), color.YellowString(st.Boost.Until.Format("2006-01-02 15:04")))
+	}
+	if st.SkipNext {
+		fmt.Printf("The next scheduled transition will be %s.\n", color.YellowString("skipped"))
 	}
 }
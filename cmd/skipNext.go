@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"github.com/jonamat/hetzner-rescaler/pkg/logger"
+	"github.com/jonamat/hetzner-rescaler/pkg/state"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(skipNextCmd)
+	skipNextCmd.Flags().Bool("cancel", false, "Do not skip the next transition anymore")
+}
+
+/* Skip-next command */
+var skipNextCmd = &cobra.Command{
+	Use:   "skip-next",
+	Short: "Skip the next scheduled transition",
+	Long:  "Skip only the next scheduled upgrade or downgrade, eg. to keep the server on the top tier for a late night",
+	Run:   RunSkipNext,
+}
+
+/* Run fn for skip-next command */
+func RunSkipNext(cmd *cobra.Command, args []string) {
+	cancel, err := cmd.Flags().GetBool("cancel")
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+
+	store, err := stateStore()
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	st, err := store.Update(func(s *state.State) {
+		s.SkipNext = !cancel
+	})
+	if err != nil {
+		logger.Errorf("Error while saving the state: %s", err.Error())
+		return
+	}
+
+	printState(st)
+}
@@ -3,15 +3,21 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/fatih/color"
 	"github.com/hetznercloud/hcloud-go/hcloud"
 	"github.com/jonamat/hetzner-rescaler/pkg/config"
+	"github.com/jonamat/hetzner-rescaler/pkg/daemon"
+	"github.com/jonamat/hetzner-rescaler/pkg/exporter"
+	"github.com/jonamat/hetzner-rescaler/pkg/logger"
 	"github.com/jonamat/hetzner-rescaler/pkg/rescaler"
+	"github.com/jonamat/hetzner-rescaler/pkg/state"
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -34,41 +40,123 @@ func init() {
 func RunStart(cmd *cobra.Command, args []string) {
 	skip, err := cmd.Flags().GetBool("skip")
 	if err != nil {
-		log.Println(color.RedString("Error: %s", err.Error()))
+		logger.Errorf("%s", err.Error())
 		return
 	}
 
 	// Get the configuration from viper
 	if err := config.CheckEnvs(); err != nil {
-		log.Println(color.RedString("Error: %s", err.Error()))
+		logger.Errorf("%s", err.Error())
 		cmd.Help()
 		return
 	}
 	hCloudToken := viper.GetString("HCLOUD_TOKEN")
 	serverId := viper.GetInt("SERVER_ID")
-	topServerName := viper.GetString("TOP_SERVER_NAME")
-	baseServerName := viper.GetString("BASE_SERVER_NAME")
-	hourStart := viper.GetString("HOUR_START")
-	hourStop := viper.GetString("HOUR_STOP")
+	sched, err := config.Schedule()
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	rescaleOpts := rescaleOptions()
+
+	// Optional Prometheus metrics, counting the failed API requests too
+	var metrics *exporter.Exporter
+	clientOpts := []hcloud.ClientOption{hcloud.WithToken(hCloudToken)}
+	metricsAddr := viper.GetString("METRICS_ADDR")
+	if metricsAddr != "" {
+		metrics = exporter.New()
+		clientOpts = append(clientOpts, hcloud.WithHTTPClient(&http.Client{Transport: metrics.Transport(nil)}))
+	}
 
 	// Create hetzner Cloud API client
-	client := hcloud.NewClient(hcloud.WithToken(hCloudToken))
+	client := hcloud.NewClient(clientOpts...)
 
 	// Get server
 	server, _, err := client.Server.GetByID(context.Background(), serverId)
 	if err != nil {
-		log.Println(color.RedString("Error while getting server: ", err.Error()))
+		logger.Errorf("Error while getting server: %s", err.Error())
 		return
 	}
 	if server == nil {
-		log.Println(color.RedString("Error: Server not found"))
+		logger.Errorf("Server not found")
+		return
+	}
+
+	// Only one instance at a time rescales the server
+	releaseLease, err := acquireLease(client, serverId, "start")
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	defer releaseLease()
+
+	// Check the server types before any shutdown happens
+	if err := validateTiers(client, server, sched); err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+
+	// Reactive scaling on the server metrics
+	autoscaler, autoscaleBounds, err := config.Autoscale(client, serverId)
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+
+	// Postpone downgrades while the server is busy
+	busyCheck, err := config.BusyCheck(client, serverId)
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+
+	// Failures are retried unless told to exit
+	errorPolicy, err := config.ErrorPolicy()
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+
+	// Compare the server with the desired state once in a while
+	reconcile, err := config.Reconcile()
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+
+	// Notice when the server is off outside of a rescale
+	watchdog, err := config.Watchdog()
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+
+	// Time left to a running rescale on shutdown
+	grace, err := config.ShutdownGrace()
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+
+	// Resolved once, viper is not safe to read while the config file is watched
+	dataDir, err := config.DataDir()
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+
+	// Pause and skip-next survive restarts
+	store := state.Open(dataDir)
+	st, err := store.Load()
+	if err != nil {
+		logger.Errorf("Error while reading the state: %s", err.Error())
 		return
 	}
 
 	// Get timezione & time info
 	location, err := time.LoadLocation(os.Getenv("TZ"))
 	if err != nil {
-		color.Red("Error while loading timezone: %s.\nFallback to UTC", err.Error())
+		logger.Warnf("Error while loading timezone: %s, fallback to UTC", err.Error())
 		location = time.UTC
 	}
 
@@ -90,15 +178,52 @@ The time on this machine is %s.
 		color.GreenString(server.Name),
 		color.GreenString(strconv.Itoa(server.ID)),
 		color.GreenString(server.ServerType.Name),
-		color.GreenString(topServerName),
-		color.GreenString(hourStart),
-		color.GreenString(baseServerName),
-		color.GreenString(hourStop),
+		color.GreenString(sched.Top.String()),
+		color.GreenString(sched.HourStart),
+		color.GreenString(sched.Base.String()),
+		color.GreenString(sched.HourStop),
 		color.GreenString(tz),
 		color.GreenString(tzOffset),
 		color.GreenString(currentTime.Format("15:04")),
 	)
 
+	if autoscaler != nil {
+		if autoscaleBounds == daemon.BoundsWindow {
+			fmt.Printf("Between %s and %s the server is rescaled according to its load.\n\n", color.GreenString(sched.HourStart), color.GreenString(sched.HourStop))
+		} else {
+			fmt.Printf("Outside %s - %s the server is rescaled according to its load.\n\n", color.GreenString(sched.HourStart), color.GreenString(sched.HourStop))
+		}
+	}
+
+	if busyCheck != nil {
+		fmt.Printf("Downgrades are deferred while the server is busy, for at most %s.\n\n", color.GreenString(busyCheck.MaxDelay.String()))
+	}
+
+	if reconcile != nil {
+		if reconcile.Policy == daemon.ReconcileCorrect {
+			fmt.Printf("Every %s a drift from the desired server type or power state is corrected.\n\n", color.GreenString(reconcile.Interval.String()))
+		} else {
+			fmt.Printf("Every %s a drift from the desired server type or power state is reported.\n\n", color.GreenString(reconcile.Interval.String()))
+		}
+	}
+
+	if watchdog != nil {
+		if watchdog.PowerOn {
+			fmt.Printf("The server is started again if it is off for %s outside of a rescale.\n\n", color.GreenString(watchdog.Grace.String()))
+		} else {
+			fmt.Printf("An alert is raised if the server is off for %s outside of a rescale.\n\n", color.GreenString(watchdog.Grace.String()))
+		}
+	}
+
+	if st.IsPaused(time.Now()) || st.Boosted(time.Now()) || st.SkipNext {
+		printState(st)
+		fmt.Println()
+	}
+
+	if rescaleOpts.DryRun {
+		logger.Warnf("Dry-run mode: API calls will be logged, the server will not be changed")
+	}
+
 	// Ask for confirmation if --skip is not set
 	if !skip {
 		confirmInput := promptui.Prompt{
@@ -106,67 +231,86 @@ The time on this machine is %s.
 		}
 		confirm, err := confirmInput.Run()
 		if err != nil {
-			log.Println(color.RedString("Error: %s", err.Error()))
+			logger.Errorf("%s", err.Error())
 			return
 		}
 
 		if confirm != "y" {
-			fmt.Println(color.RedString("Operation aborted"))
+			color.Red("Operation aborted")
 			return
 		}
 	}
 
 	/* ------------------------------- Start timer ------------------------------ */
-	log.Println(color.GreenString("Timer started\n"))
-
-	for {
-		hour, min, _ := time.Now().Clock()
-		currentHour := fmt.Sprintf("%02d:%02d", hour, min)
-
-		if currentHour == hourStart {
-			log.Println(color.GreenString("Start upgrading server..."))
-
-			if err := rescaler.Rescale(client, server, topServerName); err != nil {
-				log.Println(color.RedString("Error while resizing server: ", err.Error()))
-				return
-			}
-
-			// Update the server instance
-			server, _, err = client.Server.GetByID(context.Background(), serverId)
-			if err != nil {
-				log.Println(color.RedString("Error while getting server: ", err.Error()))
-				return
-			}
-			if server == nil {
-				log.Println(color.RedString("Error: Server not found"))
-				return
-			}
+	onResult := func(source string, result *rescaler.Result) {
+		saveResultIn(dataDir, source, result)
+	}
+	d := &daemon.Daemon{
+		Client:          client,
+		ServerID:        serverId,
+		Schedule:        sched,
+		RescaleOptions:  rescaleOpts,
+		Autoscale:       autoscaler,
+		AutoscaleBounds: autoscaleBounds,
+		Busy:            busyCheck,
+		OnResult:        onResult,
+		State:           store,
+		Metrics:         metrics,
+		ErrorPolicy:     errorPolicy,
+		Notifier:        config.Notifier(),
+		Reconcile:       reconcile,
+		Watchdog:        watchdog,
+	}
 
-			log.Println(color.GreenString("Server successfully upgraded\n"))
+	// Optional control API
+	if apiAddr := viper.GetString("API_ADDR"); apiAddr != "" {
+		apiToken := viper.GetString("API_TOKEN")
+		if apiToken == "" {
+			logger.Errorf("API_TOKEN is required by API_ADDR")
+			return
 		}
 
-		if currentHour == hourStop {
-			log.Println(color.GreenString("Start downgrading server..."))
-
-			if err := rescaler.Rescale(client, server, baseServerName); err != nil {
-				log.Println(color.RedString("Error while resizing server: ", err.Error()))
-				return
+		go func() {
+			logger.Infof("Control API listening on %s", apiAddr)
+			if err := http.ListenAndServe(apiAddr, d.Handler(apiToken)); err != nil {
+				logger.Errorf("Control API stopped: %s", err.Error())
 			}
+		}()
+	}
 
-			// Update the server instance
-			server, _, err = client.Server.GetByID(context.Background(), serverId)
-			if err != nil {
-				log.Println(color.RedString("Error while getting server: ", err.Error()))
-				return
-			}
-			if server == nil {
-				log.Println(color.RedString("Error: Server not found"))
-				return
+	if metrics != nil {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", metrics.Handler())
+			logger.Infof("Metrics listening on %s/metrics", metricsAddr)
+			if err := http.ListenAndServe(metricsAddr, mux); err != nil {
+				logger.Errorf("Metrics endpoint stopped: %s", err.Error())
 			}
+		}()
+	}
+
+	// New schedules are applied without a restart
+	if viper.ConfigFileUsed() != "" {
+		watchConfig(client, d, sched)
+	}
 
-			log.Println(color.GreenString("Server successfully downgraded\n"))
+	// Let a running rescale finish on docker stop or Ctrl+C, a second signal exits at once
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		signal.Stop(signals)
+		logger.Infof("Received %s, stopping (grace period %s)...", sig, grace)
+		if !d.Stop(grace) {
+			logger.Errorf("Rescale still running after %s, exiting anyway: it will be recovered on the next start", grace)
+			releaseLease()
+			os.Exit(1)
 		}
+	}()
 
-		time.Sleep(time.Second * 60)
+	if err := d.Run(); err != nil {
+		logger.Errorf("%s", err.Error())
+		return
 	}
+	logger.Infof("Shutdown complete, no rescale was interrupted")
 }
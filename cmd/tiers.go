@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jonamat/hetzner-rescaler/pkg/schedule"
+	"github.com/jonamat/hetzner-rescaler/pkg/tier"
+	"github.com/jonamat/hetzner-rescaler/pkg/validator"
+)
+
+// validateTiers checks the tiers of the schedule against the server before any shutdown happens.
+// Requirement tiers must match at least one server type now, and no type can be part of both tiers.
+func validateTiers(client *hcloud.Client, server *hcloud.Server, sched schedule.Schedule) error {
+	serverTypeValidator, err := validator.New(client, server)
+	if err != nil {
+		return err
+	}
+
+	var resolved [2][]string
+	for i, t := range []tier.Tier{sched.Top, sched.Base} {
+		if t.Requirements == nil {
+			if err := serverTypeValidator.Tier(t.Names); err != nil {
+				return err
+			}
+		}
+		if resolved[i], _, err = t.Resolve(client, server); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range resolved[0] {
+		if (tier.Tier{Names: resolved[1]}).Contains(name) {
+			return fmt.Errorf("server type %s is chosen for both the top and the base tier", name)
+		}
+	}
+	return nil
+}
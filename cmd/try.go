@@ -8,6 +8,7 @@ import (
 	"github.com/fatih/color"
 	"github.com/hetznercloud/hcloud-go/hcloud"
 	"github.com/jonamat/hetzner-rescaler/pkg/config"
+	"github.com/jonamat/hetzner-rescaler/pkg/logger"
 	"github.com/jonamat/hetzner-rescaler/pkg/rescaler"
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
@@ -31,20 +32,24 @@ var tryCmd = &cobra.Command{
 func RunTry(cmd *cobra.Command, args []string) {
 	skip, err := cmd.Flags().GetBool("skip")
 	if err != nil {
-		color.Red("Error: %s", err.Error())
+		logger.Errorf("%s", err.Error())
 		return
 	}
 
 	// Get the configuration from viper
 	if err := config.CheckEnvs(); err != nil {
-		color.Red("Error: %s", err.Error())
+		logger.Errorf("%s", err.Error())
 		cmd.Help()
 		return
 	}
 	hCloudToken := viper.GetString("HCLOUD_TOKEN")
 	serverId := viper.GetInt("SERVER_ID")
-	topServerName := viper.GetString("TOP_SERVER_NAME")
-	baseServerName := viper.GetString("BASE_SERVER_NAME")
+	sched, err := config.Schedule()
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	rescaleOpts := rescaleOptions()
 
 	// Create hetzner Cloud API client
 	client := hcloud.NewClient(hcloud.WithToken(hCloudToken))
@@ -52,11 +57,32 @@ func RunTry(cmd *cobra.Command, args []string) {
 	// Get server
 	server, _, err := client.Server.GetByID(context.Background(), serverId)
 	if err != nil {
-		color.Red("Error while getting server: ", err.Error())
+		logger.Errorf("Error while getting server: %s", err.Error())
 		return
 	}
 	if server == nil {
-		color.Red("Server not found")
+		logger.Errorf("Server not found")
+		return
+	}
+
+	// Only one instance at a time rescales the server
+	releaseLease, err := acquireLease(client, serverId, "try")
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+	defer releaseLease()
+
+	// Bring the server back if a previous rescale was interrupted
+	server, err = recoverRescale(client, server, rescaleOpts)
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return
+	}
+
+	// Check the server types before any shutdown happens
+	if err := validateTiers(client, server, sched); err != nil {
+		logger.Errorf("%s", err.Error())
 		return
 	}
 
@@ -67,10 +93,15 @@ func RunTry(cmd *cobra.Command, args []string) {
 		color.GreenString(server.Name),
 		color.GreenString(strconv.Itoa(server.ID)),
 		color.GreenString(server.ServerType.Name),
-		color.GreenString(topServerName),
-		color.GreenString(baseServerName),
+		color.GreenString(sched.Top.String()),
+		color.GreenString(sched.Base.String()),
 	)
 
+	fmt.Print("\n\n")
+	if rescaleOpts.DryRun {
+		logger.Warnf("Dry-run mode: API calls will be logged, the server will not be changed")
+	}
+
 	// Ask for confirmation if --skip is not set
 	if !skip {
 		confirmInput := promptui.Prompt{
@@ -78,7 +109,7 @@ func RunTry(cmd *cobra.Command, args []string) {
 		}
 		confirm, err := confirmInput.Run()
 		if err != nil {
-			color.Red("Error: %s", err.Error())
+			logger.Errorf("%s", err.Error())
 			return
 		}
 		fmt.Printf("\n\n")
@@ -90,32 +121,76 @@ func RunTry(cmd *cobra.Command, args []string) {
 	}
 
 	/* --------------------------------- Rescale -------------------------------- */
-	color.Green("Start upgrading server...")
+	logger.Infof("Start upgrading server...")
 
-	if err := rescaler.Rescale(client, server, topServerName); err != nil {
-		color.Red("Error while resizing server: ", err.Error())
+	topServerNames, reason, err := sched.Top.Resolve(client, server)
+	if err != nil {
+		logger.Errorf("Error while choosing the server type: %s", err.Error())
 		return
 	}
+	if reason != "" {
+		logger.Infof("Chosen server type %s", reason)
+	}
 
-	// Update the server instance
-	server, _, err = client.Server.GetByID(context.Background(), serverId)
+	result, err := rescaler.Rescale(client, server, topServerNames, rescaleOpts)
+	saveResult(sourceTry, result)
 	if err != nil {
-		fmt.Println(color.RedString("Error while getting server: ", err.Error()))
+		logger.Errorf("Error while resizing server: %s", err.Error())
 		return
 	}
-	if server == nil {
-		fmt.Println(color.RedString("Error: Server not found"))
-		return
+
+	// Update the server instance, in dry-run mode it is still of the old type
+	if rescaleOpts.DryRun {
+		server, err = simulateServerType(client, server, result.ToType)
+		if err != nil {
+			logger.Errorf("%s", err.Error())
+			return
+		}
+	} else {
+		server, _, err = client.Server.GetByID(context.Background(), serverId)
+		if err != nil {
+			logger.Errorf("Error while getting server: %s", err.Error())
+			return
+		}
+		if server == nil {
+			logger.Errorf("Server not found")
+			return
+		}
 	}
 
-	color.Green("Server successfully upgraded\n\n")
-	color.Green("Start downgrading server...")
+	logger.Infof("Server successfully upgraded: %s", result)
+	logger.Infof("Start downgrading server...")
+
+	baseServerNames, reason, err := sched.Base.Resolve(client, server)
+	if err != nil {
+		logger.Errorf("Error while choosing the server type: %s", err.Error())
+		return
+	}
+	if reason != "" {
+		logger.Infof("Chosen server type %s", reason)
+	}
 
-	if err := rescaler.Rescale(client, server, baseServerName); err != nil {
-		color.Red("Error while resizing server: ", err.Error())
+	result, err = rescaler.Rescale(client, server, baseServerNames, rescaleOpts)
+	saveResult(sourceTry, result)
+	if err != nil {
+		logger.Errorf("Error while resizing server: %s", err.Error())
 		return
 	}
-	color.Green("Server successfully downgraded\n\n")
+	logger.Infof("Server successfully downgraded: %s", result)
 
 	color.New(color.FgGreen).Add(color.Bold).Println("The rescale cycle has been completed succefully")
 }
+
+/* Copy of the server as if it had been rescaled to the server type */
+func simulateServerType(client *hcloud.Client, server *hcloud.Server, name string) (*hcloud.Server, error) {
+	serverType, _, err := client.ServerType.GetByName(context.Background(), name)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting server type: %s", err.Error())
+	}
+	if serverType == nil {
+		return nil, fmt.Errorf("server type %s not found", name)
+	}
+	simulated := *server
+	simulated.ServerType = serverType
+	return &simulated, nil
+}
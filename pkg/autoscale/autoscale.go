@@ -0,0 +1,110 @@
+package autoscale
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+/* Scaling decision */
+type Decision int
+
+const (
+	Hold Decision = iota
+	Upgrade
+	Downgrade
+)
+
+func (d Decision) String() string {
+	switch d {
+	case Upgrade:
+		return "upgrade"
+	case Downgrade:
+		return "downgrade"
+	default:
+		return "hold"
+	}
+}
+
+/* A measured load value, eg. the average CPU usage over a window */
+type Signal interface {
+	Name() string
+	Value(now time.Time) (float64, error)
+}
+
+/* Thresholds applied to a signal: above Up the server is upgraded, below Down it can be downgraded */
+type Trigger struct {
+	Signal Signal
+	Up     float64
+	Down   float64
+}
+
+/* Decides when to rescale from the triggers, with cooldown between two rescales */
+type Controller struct {
+	Triggers []Trigger
+	Cooldown time.Duration
+	// Minimum time between two evaluations of the triggers
+	Interval time.Duration
+
+	lastChange     time.Time
+	lastEvaluation time.Time
+}
+
+/* Check the thresholds: the gap between Down and Up is the hysteresis avoiding flapping */
+func (c *Controller) Validate() error {
+	if len(c.Triggers) == 0 {
+		return fmt.Errorf("no autoscale trigger configured")
+	}
+	for _, t := range c.Triggers {
+		if t.Down >= t.Up {
+			return fmt.Errorf("the downgrade threshold of %s must be lower than the upgrade threshold", t.Signal.Name())
+		}
+	}
+	if c.Cooldown < 0 || c.Interval < 0 {
+		return fmt.Errorf("the cooldown and the interval cannot be negative")
+	}
+	return nil
+}
+
+/* Record a rescale, starting the cooldown */
+func (c *Controller) Changed(now time.Time) {
+	c.lastChange = now
+}
+
+/* Decide whether the server, currently on the top tier or not, should change tier */
+func (c *Controller) Evaluate(now time.Time, top bool) (Decision, string, error) {
+	if !c.lastChange.IsZero() && now.Sub(c.lastChange) < c.Cooldown {
+		return Hold, fmt.Sprintf("cooldown until %s", c.lastChange.Add(c.Cooldown).Format("15:04")), nil
+	}
+	if !c.lastEvaluation.IsZero() && now.Sub(c.lastEvaluation) < c.Interval {
+		return Hold, "waiting for the next evaluation", nil
+	}
+	c.lastEvaluation = now
+
+	// Upgrade if any signal is above its threshold, downgrade only if all of them are below
+	var above, values []string
+	allBelow := true
+	for _, t := range c.Triggers {
+		value, err := t.Signal.Value(now)
+		if err != nil {
+			return Hold, "", fmt.Errorf("error while reading %s: %s", t.Signal.Name(), err.Error())
+		}
+
+		values = append(values, fmt.Sprintf("%s %.2f", t.Signal.Name(), value))
+		if value > t.Up {
+			above = append(above, fmt.Sprintf("%s %.2f > %.2f", t.Signal.Name(), value, t.Up))
+		}
+		if value >= t.Down {
+			allBelow = false
+		}
+	}
+
+	switch {
+	case !top && len(above) > 0:
+		return Upgrade, strings.Join(above, ", "), nil
+	case top && allBelow:
+		return Downgrade, strings.Join(values, ", ") + " below the downgrade thresholds", nil
+	default:
+		return Hold, strings.Join(values, ", "), nil
+	}
+}
@@ -0,0 +1,104 @@
+package autoscale
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+)
+
+/* Average CPU usage of the server in percent over a window, from the Hetzner metrics endpoint */
+type CPUSignal struct {
+	Client   *hcloud.Client
+	ServerID int
+	Window   time.Duration
+}
+
+func (s *CPUSignal) Name() string {
+	return "cpu"
+}
+
+func (s *CPUSignal) Value(now time.Time) (float64, error) {
+	return ServerAverage(s.Client, s.ServerID, hcloud.ServerMetricCPU, []string{"cpu"}, now.Add(-s.Window), now)
+}
+
+/* Average network bandwidth of the server in bytes per second (in + out) over a window */
+type NetworkSignal struct {
+	Client   *hcloud.Client
+	ServerID int
+	Window   time.Duration
+}
+
+func (s *NetworkSignal) Name() string {
+	return "network"
+}
+
+func (s *NetworkSignal) Value(now time.Time) (float64, error) {
+	return ServerAverage(s.Client, s.ServerID, hcloud.ServerMetricNetwork,
+		[]string{"network.0.bandwidth.in", "network.0.bandwidth.out"},
+		now.Add(-s.Window), now,
+	)
+}
+
+/* Sum of the averages of the named time series of a server metric between start and end */
+func ServerAverage(client *hcloud.Client, serverID int, metric hcloud.ServerMetricType, series []string, start, end time.Time) (float64, error) {
+	values, err := ServerSeries(client, serverID, metric, start, end, 60)
+	if err != nil {
+		return 0, err
+	}
+
+	var total float64
+	for _, name := range series {
+		points, ok := values[name]
+		if !ok || len(points) == 0 {
+			return 0, fmt.Errorf("no %s metrics for the server", name)
+		}
+
+		var sum float64
+		for _, p := range points {
+			sum += p.Value
+		}
+		total += sum / float64(len(points))
+	}
+
+	return total, nil
+}
+
+/* A metric value at a point in time */
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+/* Time series of a server metric between start and end, with a step in seconds */
+func ServerSeries(client *hcloud.Client, serverID int, metric hcloud.ServerMetricType, start, end time.Time, step int) (map[string][]Point, error) {
+	metrics, _, err := client.Server.GetMetrics(context.Background(), &hcloud.Server{ID: serverID}, hcloud.ServerGetMetricsOpts{
+		Types: []hcloud.ServerMetricType{metric},
+		Start: start,
+		End:   end,
+		Step:  step,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	series := map[string][]Point{}
+	for name, values := range metrics.TimeSeries {
+		for _, v := range values {
+			// Missing samples are reported as "NaN"
+			value, err := strconv.ParseFloat(v.Value, 64)
+			if err != nil || math.IsNaN(value) {
+				continue
+			}
+			series[name] = append(series[name], Point{
+				Time:  time.Unix(int64(v.Timestamp), 0),
+				Value: value,
+			})
+		}
+	}
+
+	return series, nil
+}
@@ -0,0 +1,99 @@
+package autoscale
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* Result of a PromQL instant query against a Prometheus HTTP API, which must be a single value */
+type PrometheusSignal struct {
+	// Base URL of the Prometheus server, eg. http://localhost:9090
+	URL   string
+	Query string
+	// Defaults to a client with a 10 seconds timeout
+	HTTPClient *http.Client
+}
+
+type prometheusResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+func (s *PrometheusSignal) Name() string {
+	return "prometheus"
+}
+
+func (s *PrometheusSignal) Value(now time.Time) (float64, error) {
+	httpClient := s.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	query := url.Values{}
+	query.Set("query", s.Query)
+	query.Set("time", strconv.FormatInt(now.Unix(), 10))
+
+	resp, err := httpClient.Get(strings.TrimRight(s.URL, "/") + "/api/v1/query?" + query.Encode())
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var body prometheusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, fmt.Errorf("invalid response from prometheus (status %d): %s", resp.StatusCode, err.Error())
+	}
+	if body.Status != "success" {
+		return 0, fmt.Errorf("prometheus query failed: %s: %s", body.ErrorType, body.Error)
+	}
+
+	// A sample is a [timestamp, "value"] pair
+	var sample []interface{}
+	switch body.Data.ResultType {
+	case "scalar":
+		if err := json.Unmarshal(body.Data.Result, &sample); err != nil {
+			return 0, err
+		}
+	case "vector":
+		var vector []struct {
+			Value []interface{} `json:"value"`
+		}
+		if err := json.Unmarshal(body.Data.Result, &vector); err != nil {
+			return 0, err
+		}
+		if len(vector) != 1 {
+			return 0, fmt.Errorf("the query must return a single series, got %d", len(vector))
+		}
+		sample = vector[0].Value
+	default:
+		return 0, fmt.Errorf("unsupported result type %s, use a scalar or an instant vector", body.Data.ResultType)
+	}
+
+	if len(sample) != 2 {
+		return 0, fmt.Errorf("invalid sample %v", sample)
+	}
+	value, ok := sample[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid sample value %v", sample[1])
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	// Eg. a ratio without samples, it would compare as neither high nor low
+	if math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+		return 0, fmt.Errorf("the query returned %s", value)
+	}
+	return parsed, nil
+}
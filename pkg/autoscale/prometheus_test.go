@@ -0,0 +1,114 @@
+package autoscale
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+/* Fake Prometheus answering every query with the given body */
+func fakePrometheus(t *testing.T, status int, body string) *PrometheusSignal {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if got := r.URL.Query().Get("query"); got != "up" {
+			t.Errorf("unexpected query %q", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+
+	return &PrometheusSignal{URL: server.URL + "/", Query: "up"}
+}
+
+func TestPrometheusSignalValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    float64
+		wantErr string
+	}{
+		{
+			name:   "scalar",
+			status: http.StatusOK,
+			body:   `{"status":"success","data":{"resultType":"scalar","result":[1700000000,"0.75"]}}`,
+			want:   0.75,
+		},
+		{
+			name:   "vector",
+			status: http.StatusOK,
+			body:   `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"job":"api"},"value":[1700000000,"42"]}]}}`,
+			want:   42,
+		},
+		{
+			name:    "multiple series",
+			status:  http.StatusOK,
+			body:    `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"job":"a"},"value":[1700000000,"1"]},{"metric":{"job":"b"},"value":[1700000000,"2"]}]}}`,
+			wantErr: "single series, got 2",
+		},
+		{
+			name:    "empty vector",
+			status:  http.StatusOK,
+			body:    `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+			wantErr: "single series, got 0",
+		},
+		{
+			name:    "NaN",
+			status:  http.StatusOK,
+			body:    `{"status":"success","data":{"resultType":"scalar","result":[1700000000,"NaN"]}}`,
+			wantErr: "returned NaN",
+		},
+		{
+			name:    "infinity",
+			status:  http.StatusOK,
+			body:    `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,"+Inf"]}]}}`,
+			wantErr: "returned +Inf",
+		},
+		{
+			name:    "matrix",
+			status:  http.StatusOK,
+			body:    `{"status":"success","data":{"resultType":"matrix","result":[]}}`,
+			wantErr: "unsupported result type matrix",
+		},
+		{
+			name:    "query error",
+			status:  http.StatusBadRequest,
+			body:    `{"status":"error","errorType":"bad_data","error":"parse error"}`,
+			wantErr: "bad_data: parse error",
+		},
+		{
+			name:    "invalid body",
+			status:  http.StatusBadGateway,
+			body:    `<html>bad gateway</html>`,
+			wantErr: "status 502",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signal := fakePrometheus(t, tt.status, tt.body)
+			got, err := signal.Value(time.Unix(1700000000, 0))
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v (value %v)", tt.wantErr, err, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
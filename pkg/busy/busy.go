@@ -0,0 +1,117 @@
+package busy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/jonamat/hetzner-rescaler/pkg/autoscale"
+)
+
+/* Tells whether the server is doing work that a downgrade would interrupt */
+type Probe interface {
+	Name() string
+	Busy(now time.Time) (bool, error)
+}
+
+/* Probes checked before a downgrade, postponing it up to MaxDelay */
+type Check struct {
+	Probes []Probe
+	// Time between two checks while the server is busy
+	Recheck  time.Duration
+	MaxDelay time.Duration
+}
+
+/* Reasons why the server is busy, none if it's idle. A failing probe counts as busy */
+func (c *Check) Busy(now time.Time) []string {
+	var reasons []string
+	for _, p := range c.Probes {
+		busy, err := p.Busy(now)
+		if err != nil {
+			reasons = append(reasons, fmt.Sprintf("%s probe failed: %s", p.Name(), err.Error()))
+			continue
+		}
+		if busy {
+			reasons = append(reasons, fmt.Sprintf("%s probe reports busy", p.Name()))
+		}
+	}
+	return reasons
+}
+
+/* Busy while the average CPU usage is above the threshold */
+type CPUProbe struct {
+	Signal    *autoscale.CPUSignal
+	Threshold float64
+}
+
+func (p *CPUProbe) Name() string {
+	return "cpu"
+}
+
+func (p *CPUProbe) Busy(now time.Time) (bool, error) {
+	value, err := p.Signal.Value(now)
+	if err != nil {
+		return false, err
+	}
+	return value > p.Threshold, nil
+}
+
+/* Busy when the output of the shell command is "busy" */
+type CommandProbe struct {
+	Command string
+	Timeout time.Duration
+}
+
+func (p *CommandProbe) Name() string {
+	return "command"
+}
+
+func (p *CommandProbe) Busy(now time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+	defer cancel()
+
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+
+	out, err := exec.CommandContext(ctx, shell, flag, p.Command).Output()
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(string(out)) == "busy", nil
+}
+
+/* Busy when the body of the HTTP endpoint is "busy" */
+type HTTPProbe struct {
+	URL     string
+	Timeout time.Duration
+}
+
+func (p *HTTPProbe) Name() string {
+	return "http"
+}
+
+func (p *HTTPProbe) Busy(now time.Time) (bool, error) {
+	client := &http.Client{Timeout: p.Timeout}
+	resp, err := client.Get(p.URL)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return false, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(string(body)) == "busy", nil
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jonamat/hetzner-rescaler/pkg/autoscale"
+	"github.com/jonamat/hetzner-rescaler/pkg/daemon"
+	"github.com/spf13/viper"
+)
+
+/* Reactive scaling controller and its schedule bounds, nil if autoscale is disabled */
+func Autoscale(client *hcloud.Client, serverID int) (*autoscale.Controller, string, error) {
+	if !viper.GetBool("AUTOSCALE_ENABLED") {
+		return nil, "", nil
+	}
+
+	viper.SetDefault("AUTOSCALE_BOUNDS", daemon.BoundsFloor)
+	viper.SetDefault("AUTOSCALE_WINDOW", "10m")
+	viper.SetDefault("AUTOSCALE_COOLDOWN", "30m")
+	viper.SetDefault("AUTOSCALE_INTERVAL", "1m")
+	viper.SetDefault("AUTOSCALE_CPU_ENABLED", true)
+	viper.SetDefault("AUTOSCALE_CPU_UP", 80)
+	viper.SetDefault("AUTOSCALE_CPU_DOWN", 20)
+
+	bounds := viper.GetString("AUTOSCALE_BOUNDS")
+	if bounds != daemon.BoundsFloor && bounds != daemon.BoundsWindow {
+		return nil, "", fmt.Errorf("invalid AUTOSCALE_BOUNDS %q, use floor or window", bounds)
+	}
+
+	window, err := time.ParseDuration(viper.GetString("AUTOSCALE_WINDOW"))
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid AUTOSCALE_WINDOW: %s", err.Error())
+	}
+	cooldown, err := time.ParseDuration(viper.GetString("AUTOSCALE_COOLDOWN"))
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid AUTOSCALE_COOLDOWN: %s", err.Error())
+	}
+	interval, err := time.ParseDuration(viper.GetString("AUTOSCALE_INTERVAL"))
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid AUTOSCALE_INTERVAL: %s", err.Error())
+	}
+
+	controller := &autoscale.Controller{
+		Cooldown: cooldown,
+		Interval: interval,
+	}
+
+	if viper.GetBool("AUTOSCALE_CPU_ENABLED") {
+		controller.Triggers = append(controller.Triggers, autoscale.Trigger{
+			Signal: &autoscale.CPUSignal{Client: client, ServerID: serverID, Window: window},
+			Up:     viper.GetFloat64("AUTOSCALE_CPU_UP"),
+			Down:   viper.GetFloat64("AUTOSCALE_CPU_DOWN"),
+		})
+	}
+
+	// Network is optional, enabled by its upgrade threshold
+	if viper.IsSet("AUTOSCALE_NETWORK_UP") {
+		controller.Triggers = append(controller.Triggers, autoscale.Trigger{
+			Signal: &autoscale.NetworkSignal{Client: client, ServerID: serverID, Window: window},
+			Up:     viper.GetFloat64("AUTOSCALE_NETWORK_UP"),
+			Down:   viper.GetFloat64("AUTOSCALE_NETWORK_DOWN"),
+		})
+	}
+
+	// Load signals living in Prometheus, eg. request latency or queue length
+	if viper.GetString("AUTOSCALE_PROMETHEUS_QUERY") != "" {
+		if viper.GetString("AUTOSCALE_PROMETHEUS_URL") == "" {
+			return nil, "", fmt.Errorf("AUTOSCALE_PROMETHEUS_URL is required by AUTOSCALE_PROMETHEUS_QUERY")
+		}
+		if !viper.IsSet("AUTOSCALE_PROMETHEUS_UP") || !viper.IsSet("AUTOSCALE_PROMETHEUS_DOWN") {
+			return nil, "", fmt.Errorf("AUTOSCALE_PROMETHEUS_UP and AUTOSCALE_PROMETHEUS_DOWN are required by AUTOSCALE_PROMETHEUS_QUERY")
+		}
+		controller.Triggers = append(controller.Triggers, autoscale.Trigger{
+			Signal: &autoscale.PrometheusSignal{
+				URL:   viper.GetString("AUTOSCALE_PROMETHEUS_URL"),
+				Query: viper.GetString("AUTOSCALE_PROMETHEUS_QUERY"),
+			},
+			Up:   viper.GetFloat64("AUTOSCALE_PROMETHEUS_UP"),
+			Down: viper.GetFloat64("AUTOSCALE_PROMETHEUS_DOWN"),
+		})
+	}
+
+	if err := controller.Validate(); err != nil {
+		return nil, "", err
+	}
+
+	return controller, bounds, nil
+}
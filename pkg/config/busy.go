@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jonamat/hetzner-rescaler/pkg/autoscale"
+	"github.com/jonamat/hetzner-rescaler/pkg/busy"
+	"github.com/spf13/viper"
+)
+
+/* Checks postponing downgrades while the server is busy, nil if no probe is configured */
+func BusyCheck(client *hcloud.Client, serverID int) (*busy.Check, error) {
+	viper.SetDefault("BUSY_CPU_WINDOW", "5m")
+	viper.SetDefault("BUSY_PROBE_TIMEOUT", "10s")
+	viper.SetDefault("BUSY_RECHECK", "5m")
+	viper.SetDefault("BUSY_MAX_DELAY", "2h")
+
+	durations := map[string]time.Duration{}
+	for _, key := range []string{"BUSY_CPU_WINDOW", "BUSY_PROBE_TIMEOUT", "BUSY_RECHECK", "BUSY_MAX_DELAY"} {
+		d, err := time.ParseDuration(viper.GetString(key))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", key, err.Error())
+		}
+		durations[key] = d
+	}
+
+	check := &busy.Check{
+		Recheck:  durations["BUSY_RECHECK"],
+		MaxDelay: durations["BUSY_MAX_DELAY"],
+	}
+
+	if viper.IsSet("BUSY_CPU_THRESHOLD") {
+		check.Probes = append(check.Probes, &busy.CPUProbe{
+			Signal:    &autoscale.CPUSignal{Client: client, ServerID: serverID, Window: durations["BUSY_CPU_WINDOW"]},
+			Threshold: viper.GetFloat64("BUSY_CPU_THRESHOLD"),
+		})
+	}
+	if command := viper.GetString("BUSY_COMMAND"); command != "" {
+		check.Probes = append(check.Probes, &busy.CommandProbe{Command: command, Timeout: durations["BUSY_PROBE_TIMEOUT"]})
+	}
+	if url := viper.GetString("BUSY_URL"); url != "" {
+		check.Probes = append(check.Probes, &busy.HTTPProbe{URL: url, Timeout: durations["BUSY_PROBE_TIMEOUT"]})
+	}
+
+	if len(check.Probes) == 0 {
+		return nil, nil
+	}
+	return check, nil
+}
@@ -30,12 +30,22 @@ func CheckEnvs() error {
 
 	if viper.GetString("HCLOUD_TOKEN") == "" ||
 		viper.GetInt("SERVER_ID") == 0 ||
-		viper.GetString("TOP_SERVER_NAME") == "" ||
-		viper.GetString("BASE_SERVER_NAME") == "" ||
 		viper.GetString("HOUR_START") == "" ||
 		viper.GetString("HOUR_STOP") == "" {
 		return fmt.Errorf("missing or incomplete configuration")
 	}
 
+	// Check the schedule hours and tiers
+	if _, err := Schedule(); err != nil {
+		return err
+	}
+
+	// A typo would silently disable the check
+	for _, protection := range List("PREFLIGHT_REFUSE_PROTECTION") {
+		if protection != "delete" && protection != "rebuild" {
+			return fmt.Errorf("invalid PREFLIGHT_REFUSE_PROTECTION value %s, expected delete or rebuild", protection)
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jonamat/hetzner-rescaler/pkg/daemon"
+	"github.com/spf13/viper"
+)
+
+/* What the daemon does when a transition or an API call fails */
+func ErrorPolicy() (daemon.ErrorPolicy, error) {
+	viper.SetDefault("ERROR_POLICY", "retry")
+	viper.SetDefault("RETRY_MIN", "1m")
+	viper.SetDefault("RETRY_MAX", "30m")
+
+	var policy daemon.ErrorPolicy
+	switch value := viper.GetString("ERROR_POLICY"); value {
+	case "retry":
+	case "exit":
+		policy.Exit = true
+	default:
+		return policy, fmt.Errorf("invalid ERROR_POLICY %q, expected retry or exit", value)
+	}
+
+	var err error
+	if policy.RetryMin, err = time.ParseDuration(viper.GetString("RETRY_MIN")); err != nil {
+		return policy, fmt.Errorf("invalid RETRY_MIN: %s", err.Error())
+	}
+	if policy.RetryMax, err = time.ParseDuration(viper.GetString("RETRY_MAX")); err != nil {
+		return policy, fmt.Errorf("invalid RETRY_MAX: %s", err.Error())
+	}
+	if policy.RetryMin <= 0 || policy.RetryMax < policy.RetryMin {
+		return policy, fmt.Errorf("RETRY_MIN must be positive and not greater than RETRY_MAX")
+	}
+	return policy, nil
+}
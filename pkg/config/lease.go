@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jonamat/hetzner-rescaler/pkg/lease"
+	"github.com/spf13/viper"
+)
+
+/* Lease of the server for the given command and its time to live, nil if disabled */
+func Lease(client *hcloud.Client, serverID int, command string) (lease.Lease, time.Duration, error) {
+	viper.SetDefault("LEASE_BACKEND", "file")
+	viper.SetDefault("LEASE_TTL", "10m")
+
+	ttl, err := time.ParseDuration(viper.GetString("LEASE_TTL"))
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid LEASE_TTL: %s", err.Error())
+	}
+	if ttl < time.Minute {
+		return nil, 0, fmt.Errorf("LEASE_TTL must be at least 1m")
+	}
+
+	self := lease.Self(command)
+	switch backend := viper.GetString("LEASE_BACKEND"); backend {
+	case "file":
+		dir, err := DataDir()
+		if err != nil {
+			return nil, 0, err
+		}
+		return lease.NewFileLease(dir, serverID, self), ttl, nil
+	case "label":
+		return lease.NewLabelLease(client, serverID, self), ttl, nil
+	case "none":
+		return nil, 0, nil
+	default:
+		return nil, 0, fmt.Errorf("invalid LEASE_BACKEND %q, expected file, label or none", backend)
+	}
+}
+
+/* Whether the lease holder shares the data dir, and so the state, of this instance */
+func LeaseSharesDataDir() bool {
+	viper.SetDefault("LEASE_BACKEND", "file")
+	return viper.GetString("LEASE_BACKEND") == "file"
+}
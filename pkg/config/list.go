@@ -0,0 +1,32 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+/* Values of a comma separated string or a yaml list, blanks are dropped */
+func List(key string) []string {
+	var raw []string
+	switch value := viper.Get(key).(type) {
+	case []interface{}:
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				raw = append(raw, s)
+			}
+		}
+	case []string:
+		raw = value
+	default:
+		raw = strings.Split(viper.GetString(key), ",")
+	}
+
+	var values []string
+	for _, value := range raw {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
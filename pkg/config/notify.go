@@ -0,0 +1,22 @@
+package config
+
+import (
+	"github.com/jonamat/hetzner-rescaler/pkg/notify"
+	"github.com/spf13/viper"
+)
+
+/* Channels receiving the alerts of the daemon, nil if none is configured */
+func Notifier() notify.Notifier {
+	var notifiers notify.Multi
+	for _, url := range List("NOTIFY_WEBHOOK_URL") {
+		notifiers = append(notifiers, &notify.Webhook{
+			URL:           url,
+			Authorization: viper.GetString("NOTIFY_WEBHOOK_AUTHORIZATION"),
+		})
+	}
+
+	if len(notifiers) == 0 {
+		return nil
+	}
+	return notifiers
+}
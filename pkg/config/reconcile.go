@@ -0,0 +1,34 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jonamat/hetzner-rescaler/pkg/daemon"
+	"github.com/spf13/viper"
+)
+
+/* Drift detection of the daemon, nil if disabled */
+func Reconcile() (*daemon.Reconcile, error) {
+	viper.SetDefault("RECONCILE_POLICY", "off")
+	viper.SetDefault("RECONCILE_INTERVAL", "5m")
+
+	policy := viper.GetString("RECONCILE_POLICY")
+	switch policy {
+	case "off":
+		return nil, nil
+	case daemon.ReconcileAlert, daemon.ReconcileCorrect:
+	default:
+		return nil, fmt.Errorf("invalid RECONCILE_POLICY %q, expected off, alert or correct", policy)
+	}
+
+	interval, err := time.ParseDuration(viper.GetString("RECONCILE_INTERVAL"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RECONCILE_INTERVAL: %s", err.Error())
+	}
+	if interval < time.Minute {
+		return nil, fmt.Errorf("RECONCILE_INTERVAL must be at least 1m")
+	}
+
+	return &daemon.Reconcile{Policy: policy, Interval: interval}, nil
+}
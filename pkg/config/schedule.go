@@ -0,0 +1,35 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/jonamat/hetzner-rescaler/pkg/schedule"
+	"github.com/spf13/viper"
+)
+
+/* Daily schedule from the configuration */
+func Schedule() (schedule.Schedule, error) {
+	top, err := ServerTier("TOP")
+	if err != nil {
+		return schedule.Schedule{}, err
+	}
+	base, err := ServerTier("BASE")
+	if err != nil {
+		return schedule.Schedule{}, err
+	}
+	if top.Empty() || base.Empty() {
+		return schedule.Schedule{}, fmt.Errorf("missing or incomplete configuration")
+	}
+
+	sched := schedule.Schedule{
+		HourStart: viper.GetString("HOUR_START"),
+		HourStop:  viper.GetString("HOUR_STOP"),
+		Top:       top,
+		Base:      base,
+	}
+	if err := sched.Validate(); err != nil {
+		return schedule.Schedule{}, err
+	}
+
+	return sched, nil
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jonamat/hetzner-rescaler/pkg/tier"
+	"github.com/spf13/viper"
+)
+
+/* Tier of the given kind ("TOP" or "BASE"), from <kind>_SERVER_REQUIREMENTS if set, <kind>_SERVER_NAME otherwise */
+func ServerTier(kind string) (tier.Tier, error) {
+	key := kind + "_SERVER_REQUIREMENTS"
+	if !viper.IsSet(key) || (viper.GetString(key) == "" && len(viper.GetStringMap(key)) == 0) {
+		return tier.Tier{Names: List(kind + "_SERVER_NAME")}, nil
+	}
+
+	// Yaml map or comma separated key=value pairs
+	values := map[string]string{}
+	if m := viper.GetStringMap(key); len(m) > 0 {
+		for k, v := range m {
+			values[strings.ToLower(k)] = fmt.Sprint(v)
+		}
+	} else {
+		for _, pair := range strings.Split(viper.GetString(key), ",") {
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 {
+				return tier.Tier{}, fmt.Errorf("invalid %s %q, use key=value pairs", key, pair)
+			}
+			values[strings.ToLower(strings.TrimSpace(kv[0]))] = strings.TrimSpace(kv[1])
+		}
+	}
+
+	var req tier.Requirements
+	for k, v := range values {
+		var err error
+		switch k {
+		case "min_cores":
+			req.MinCores, err = strconv.Atoi(v)
+		case "min_memory":
+			var memory float64
+			memory, err = strconv.ParseFloat(v, 32)
+			req.MinMemory = float32(memory)
+		case "cpu_type":
+			req.CPUType = v
+		case "architecture":
+			req.Architecture = v
+		default:
+			err = fmt.Errorf("unknown requirement")
+		}
+		if err != nil {
+			return tier.Tier{}, fmt.Errorf("invalid %s %s=%s: %s", key, k, v, err.Error())
+		}
+	}
+	if err := req.Validate(); err != nil {
+		return tier.Tier{}, fmt.Errorf("invalid %s: %s", key, err.Error())
+	}
+
+	return tier.Tier{Requirements: &req}, nil
+}
@@ -0,0 +1,22 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+/* Time given to a running rescale to finish once the daemon is asked to stop */
+func ShutdownGrace() (time.Duration, error) {
+	viper.SetDefault("SHUTDOWN_GRACE", "5m")
+
+	grace, err := time.ParseDuration(viper.GetString("SHUTDOWN_GRACE"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid SHUTDOWN_GRACE: %s", err.Error())
+	}
+	if grace < 0 {
+		return 0, fmt.Errorf("SHUTDOWN_GRACE cannot be negative")
+	}
+	return grace, nil
+}
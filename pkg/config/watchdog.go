@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jonamat/hetzner-rescaler/pkg/daemon"
+	"github.com/spf13/viper"
+)
+
+/* Watchdog of the daemon for a server unexpectedly off, nil if disabled */
+func Watchdog() (*daemon.Watchdog, error) {
+	viper.SetDefault("WATCHDOG_POLICY", "off")
+	viper.SetDefault("WATCHDOG_GRACE", "2m")
+
+	watchdog := &daemon.Watchdog{}
+	switch policy := viper.GetString("WATCHDOG_POLICY"); policy {
+	case "off":
+		return nil, nil
+	case "alert":
+	case "power-on":
+		watchdog.PowerOn = true
+	default:
+		return nil, fmt.Errorf("invalid WATCHDOG_POLICY %q, expected off, alert or power-on", policy)
+	}
+
+	grace, err := time.ParseDuration(viper.GetString("WATCHDOG_GRACE"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid WATCHDOG_GRACE: %s", err.Error())
+	}
+	if grace < 0 {
+		return nil, fmt.Errorf("WATCHDOG_GRACE cannot be negative")
+	}
+	watchdog.Grace = grace
+
+	return watchdog, nil
+}
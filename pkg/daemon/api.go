@@ -0,0 +1,92 @@
+package daemon
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jonamat/hetzner-rescaler/pkg/state"
+)
+
+/* HTTP control API of the daemon, protected by a bearer token */
+func (d *Daemon) Handler(token string) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		writeJSON(w, http.StatusOK, d.Status())
+	})
+
+	mux.HandleFunc("/upgrade", d.post(func() error {
+		return d.Trigger(true, SourceAPI)
+	}))
+	mux.HandleFunc("/downgrade", d.post(func() error {
+		return d.Trigger(false, SourceAPI)
+	}))
+	mux.HandleFunc("/pause", func(w http.ResponseWriter, r *http.Request) {
+		d.post(func() error {
+			// Optional automatic resume, eg. ?until=2h
+			var until time.Time
+			if value := r.URL.Query().Get("until"); value != "" {
+				var err error
+				if until, err = state.ResumeTime(value, time.Now()); err != nil {
+					return err
+				}
+			}
+			return d.Pause(until)
+		})(w, r)
+	})
+	mux.HandleFunc("/resume", d.post(d.Resume))
+	mux.HandleFunc("/skip-next", d.post(func() error {
+		return d.SkipNext(true)
+	}))
+	mux.HandleFunc("/unskip-next", d.post(func() error {
+		return d.SkipNext(false)
+	}))
+
+	return authenticate(token, mux)
+}
+
+/* Handler of an operation, replying with the status once accepted */
+func (d *Daemon) post(operation func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		if err := operation(); err != nil {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusAccepted, d.Status())
+	}
+}
+
+/* Reject the requests without the bearer token */
+func authenticate(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		provided := strings.TrimPrefix(header, "Bearer ")
+		if token == "" || !strings.HasPrefix(header, "Bearer ") || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
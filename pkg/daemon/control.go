@@ -0,0 +1,210 @@
+package daemon
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jonamat/hetzner-rescaler/pkg/rescaler"
+	"github.com/jonamat/hetzner-rescaler/pkg/schedule"
+	"github.com/jonamat/hetzner-rescaler/pkg/state"
+	"github.com/jonamat/hetzner-rescaler/pkg/tier"
+)
+
+/* Snapshot of the daemon */
+type Status struct {
+	ServerID     int    `json:"server_id"`
+	ServerName   string `json:"server_name"`
+	ServerType   string `json:"server_type"`
+	ServerStatus string `json:"server_status"`
+	// "top" or "base"
+	Tier   string `json:"tier"`
+	Paused bool   `json:"paused"`
+	// Automatic resume of a paused schedule, if any
+	ResumeAt *time.Time `json:"resume_at,omitempty"`
+	// Whether the next scheduled transition will be skipped
+	SkipNext bool `json:"skip_next"`
+	// Temporary upgrade, if any
+	Boost     *state.Boost `json:"boost,omitempty"`
+	Rescaling bool         `json:"rescaling"`
+	// Tier of the transition waiting to be done, if any
+	Pending         string           `json:"pending,omitempty"`
+	PendingSource   string           `json:"pending_source,omitempty"`
+	Schedule        ScheduleStatus   `json:"schedule"`
+	Autoscale       bool             `json:"autoscale"`
+	AutoscaleBounds string           `json:"autoscale_bounds,omitempty"`
+	LastResult      *rescaler.Result `json:"last_result,omitempty"`
+	// Set while failures are being retried
+	Degraded  bool   `json:"degraded"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+/* Schedule part of the status */
+type ScheduleStatus struct {
+	HourStart      string    `json:"hour_start"`
+	HourStop       string    `json:"hour_stop"`
+	Top            string    `json:"top"`
+	Base           string    `json:"base"`
+	NextTransition time.Time `json:"next_transition"`
+	NextTier       string    `json:"next_tier"`
+}
+
+/* Current state of the daemon */
+func (d *Daemon) Status() Status {
+	now := time.Now()
+	st, _ := d.store().Load()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	next, nextTier := d.Schedule.Next(now)
+	status := Status{
+		ServerID:  d.ServerID,
+		Tier:      tierName(d.top),
+		Paused:    st.IsPaused(now),
+		SkipNext:  st.SkipNext,
+		Rescaling: d.running != nil,
+		Schedule: ScheduleStatus{
+			HourStart:      d.Schedule.HourStart,
+			HourStop:       d.Schedule.HourStop,
+			Top:            d.Schedule.Top.String(),
+			Base:           d.Schedule.Base.String(),
+			NextTransition: next,
+			NextTier:       nextTier.String(),
+		},
+		Autoscale:  d.Autoscale != nil,
+		LastResult: d.lastResult,
+		Degraded:   d.failures > 0,
+		LastError:  d.lastError,
+	}
+	if st.Boosted(now) {
+		status.Boost = st.Boost
+	}
+	if status.Paused && !st.ResumeAt.IsZero() {
+		status.ResumeAt = &st.ResumeAt
+	}
+	if d.Autoscale != nil {
+		status.AutoscaleBounds = d.AutoscaleBounds
+	}
+	if d.server != nil {
+		status.ServerName = d.server.Name
+		status.ServerType = d.server.ServerType.Name
+		status.ServerStatus = string(d.server.Status)
+	}
+	if d.pending != nil {
+		status.Pending = tierName(d.pending.top)
+		status.PendingSource = d.pending.source
+	}
+
+	return status
+}
+
+/* Ask for an immediate rescale to the top or base tier */
+func (d *Daemon) Trigger(top bool, source string) error {
+	st, err := d.store().Load()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if st.IsPaused(now) {
+		return fmt.Errorf("the scheduling is paused")
+	}
+	if st.Boosted(now) {
+		return fmt.Errorf("the server is boosted until %s", st.Boost.Until.Format(time.RFC3339))
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopping {
+		return fmt.Errorf("the daemon is stopping")
+	}
+	if top {
+		d.setPending(d.upgrade(source))
+	} else {
+		d.setPending(d.downgrade(source))
+	}
+	d.wakeUp()
+	return nil
+}
+
+/* Replace the schedule, the pending transition is moved to the new tiers */
+func (d *Daemon) SetSchedule(sched schedule.Schedule) error {
+	// Whether the server is already on the new top tier
+	server := d.currentServer()
+	var names []string
+	if server != nil {
+		var err error
+		names, _, err = sched.Top.Resolve(d.Client, server)
+		if err != nil {
+			return err
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.Schedule = sched
+	if server != nil {
+		d.top = tier.Tier{Names: names}.Contains(server.ServerType.Name)
+	}
+	// The tier of a transition is only read with the lock held
+	if t := d.pending; t != nil && !t.boost {
+		t.tier = sched.Base
+		if t.top {
+			t.tier = sched.Top
+		}
+	}
+	d.wakeUp()
+	return nil
+}
+
+/* Stop scheduling transitions until resumed, or until the given time if not zero */
+func (d *Daemon) Pause(until time.Time) error {
+	_, err := d.store().Update(func(s *state.State) {
+		s.Paused = true
+		s.PausedAt = time.Now()
+		s.ResumeAt = until
+	})
+	return err
+}
+
+/* Resume scheduling transitions */
+func (d *Daemon) Resume() error {
+	_, err := d.store().Update(func(s *state.State) {
+		s.Resume()
+	})
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.wakeUp()
+	return nil
+}
+
+/* Skip the next scheduled transition, or cancel the skip */
+func (d *Daemon) SkipNext(skip bool) error {
+	_, err := d.store().Update(func(s *state.State) {
+		s.SkipNext = skip
+	})
+	return err
+}
+
+/* Run the next tick now, requires the lock */
+func (d *Daemon) wakeUp() {
+	if d.wake == nil {
+		return
+	}
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func tierName(top bool) string {
+	if top {
+		return "top"
+	}
+	return "base"
+}
@@ -0,0 +1,659 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jonamat/hetzner-rescaler/pkg/autoscale"
+	"github.com/jonamat/hetzner-rescaler/pkg/busy"
+	"github.com/jonamat/hetzner-rescaler/pkg/exporter"
+	"github.com/jonamat/hetzner-rescaler/pkg/logger"
+	"github.com/jonamat/hetzner-rescaler/pkg/notify"
+	"github.com/jonamat/hetzner-rescaler/pkg/rescaler"
+	"github.com/jonamat/hetzner-rescaler/pkg/schedule"
+	"github.com/jonamat/hetzner-rescaler/pkg/state"
+	"github.com/jonamat/hetzner-rescaler/pkg/tier"
+)
+
+/* How the time schedule bounds autoscaling */
+const (
+	// Top tier between HourStart and HourStop, autoscale outside
+	BoundsFloor = "floor"
+	// Autoscale between HourStart and HourStop, base tier outside
+	BoundsWindow = "window"
+)
+
+/* What asked for a rescale */
+const (
+	SourceSchedule  = "schedule"
+	SourceAutoscale = "autoscale"
+	SourceAPI       = "api"
+	SourceBoost     = "boost"
+	SourceRecovery  = "recovery"
+	SourceReconcile = "reconcile"
+)
+
+/* Rescales the server following the schedule and, optionally, the load */
+type Daemon struct {
+	Client   *hcloud.Client
+	ServerID int
+	// Replaced at runtime by SetSchedule, read with the lock held
+	Schedule       schedule.Schedule
+	RescaleOptions rescaler.Options
+	// Reactive scaling, disabled if nil
+	Autoscale       *autoscale.Controller
+	AutoscaleBounds string
+	// Postpones downgrades while the server is busy, disabled if nil
+	Busy *busy.Check
+	// Called after every rescale attempt with what asked for it
+	OnResult func(source string, result *rescaler.Result)
+	// Pause and skip-next state, kept in memory if nil
+	State *state.Store
+	// Prometheus metrics, disabled if nil
+	Metrics *exporter.Exporter
+	// Failures are retried with backoff unless told to exit
+	ErrorPolicy ErrorPolicy
+	// Alerts, disabled if nil
+	Notifier notify.Notifier
+	// Drift detection, disabled if nil
+	Reconcile *Reconcile
+	// Alerts when the server is unexpectedly off, disabled if nil
+	Watchdog *Watchdog
+
+	stateOnce sync.Once
+	// Used by the timer only
+	log *logger.Logger
+
+	// Protects the fields below, shared with the control API
+	mu     sync.Mutex
+	server *hcloud.Server
+	// Whether the server is on the top tier
+	top bool
+	// Transition waiting to be done, kept until it succeeds if the rescale is postponed
+	pending *transition
+	// Transition being done right now
+	running    *transition
+	lastResult *rescaler.Result
+	// Wakes up the timer before the next tick
+	wake chan struct{}
+	// Set by Stop, no transition is started afterwards
+	stopping bool
+	// Closed when Run returns
+	stopped chan struct{}
+	// Consecutive failures, the server is degraded if not zero
+	failures  int
+	lastError string
+	// Last drift found by the reconcile, empty if none
+	drift         string
+	lastReconcile time.Time
+	// Tier of the last transition asked for or skipped, the reconcile follows it instead of the schedule if set
+	intent    bool
+	hasIntent bool
+	// Time of the last tick, the transitions up to it are already scheduled, so a wake-up never schedules them twice
+	lastCheck time.Time
+	// Since when the watchdog sees the server off, zero if it is not
+	offSince    time.Time
+	offAlerted  bool
+	poweredOnAt time.Time
+}
+
+/* A rescale to do, the tier and the timings are accessed with the lock held once it is pending */
+type transition struct {
+	top    bool
+	tier   tier.Tier
+	source string
+	doing  string
+	done   string
+	// Upgrade asked by a boost, run even while paused or boosted
+	boost bool
+	// Set when the transition was deferred because the server is busy
+	deferredSince time.Time
+	nextCheck     time.Time
+	// Set when the transition failed, it is not tried again before
+	retryAt time.Time
+}
+
+/* Run the timer until an unrecoverable error occurs or Stop is called, in which case nil is returned */
+func (d *Daemon) Run() error {
+	d.mu.Lock()
+	d.wake = make(chan struct{}, 1)
+	d.stopped = make(chan struct{})
+	stopped := d.stopped
+	d.mu.Unlock()
+	defer close(stopped)
+
+	if d.Metrics != nil {
+		d.Metrics.Degraded(d.ServerID, false)
+	}
+
+	// Retried until the server can be reached, unless the error policy says otherwise
+	for {
+		err := d.setup()
+		if err == nil {
+			break
+		}
+		if d.ErrorPolicy.Exit || unrecoverable(err) {
+			return err
+		}
+		if !d.sleep(d.failed(err)) {
+			return nil
+		}
+	}
+	d.recovered()
+
+	d.log.Infof("Timer started")
+
+	for !d.isStopping() {
+		// Failed transitions are retried by the next ticks, unless the error policy says otherwise
+		if err := d.tick(time.Now()); err != nil {
+			return err
+		}
+
+		// The transitions missed while working or sleeping are caught by the next tick
+		now := time.Now()
+		select {
+		case <-time.After(now.Truncate(time.Minute).Add(time.Minute).Sub(now)):
+		case <-d.wake:
+		}
+	}
+
+	d.log.Infof("Timer stopped")
+	return nil
+}
+
+/* Fetch the server, recover an interrupted rescale and find the initial tier */
+func (d *Daemon) setup() error {
+	d.log = logger.With(logger.Fields{logger.FieldServerID: d.ServerID})
+	if err := d.refresh(); err != nil {
+		return err
+	}
+
+	server := d.currentServer()
+	d.log = d.log.With(logger.Fields{logger.FieldServerName: server.Name})
+
+	// A rescale interrupted by a crash may have left the server off
+	if err := d.recover(server); err != nil {
+		return err
+	}
+
+	// Initial tier of the server, needed by autoscale
+	server = d.currentServer()
+	d.mu.Lock()
+	top := d.Schedule.Top
+	d.mu.Unlock()
+	names, _, err := top.Resolve(d.Client, server)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.top = tier.Tier{Names: names}.Contains(server.ServerType.Name)
+	d.mu.Unlock()
+	return nil
+}
+
+/* Wait for the given time, false if the daemon is stopped meanwhile */
+func (d *Daemon) sleep(duration time.Duration) bool {
+	deadline := time.After(duration)
+	for !d.isStopping() {
+		select {
+		case <-deadline:
+			return true
+		case <-d.wake:
+		}
+	}
+	return false
+}
+
+/* Stop scheduling transitions and wait up to grace for the running rescale, false if it is still running */
+func (d *Daemon) Stop(grace time.Duration) bool {
+	d.mu.Lock()
+	d.stopping = true
+	stopped := d.stopped
+	if d.running != nil {
+		d.log.Infof("Waiting for the server to finish %s...", d.running.doing)
+	}
+	d.wakeUp()
+	d.mu.Unlock()
+
+	if stopped == nil {
+		return true
+	}
+
+	select {
+	case <-stopped:
+		return true
+	case <-time.After(grace):
+		return false
+	}
+}
+
+func (d *Daemon) isStopping() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.stopping
+}
+
+/* Schedule the transitions due at the given time and run the pending one */
+func (d *Daemon) tick(now time.Time) error {
+	// The first tick also catches a transition at the current minute
+	d.mu.Lock()
+	since := d.lastCheck
+	if since.IsZero() {
+		since = now.Truncate(time.Minute).Add(-time.Nanosecond)
+	}
+	d.lastCheck = now
+	d.mu.Unlock()
+
+	st := d.state(now)
+	paused := st.IsPaused(now)
+	boosted := st.Boosted(now)
+
+	// Back to the schedule once the boost is over
+	var revert bool
+	if st.Boost != nil && !boosted {
+		ended, err := d.store().EndBoost(*st.Boost)
+		if err != nil {
+			d.log.Errorf("Error while saving the state: %s", err.Error())
+		}
+		revert = ended
+	}
+
+	// Boosts saved by a command which could not take the lease are applied here
+	var apply bool
+	if boosted && st.Boost.Delegated {
+		start := st.Boost.Start
+		_, err := d.store().Update(func(s *state.State) {
+			if s.Boost != nil && s.Boost.Start.Equal(start) && s.Boost.Delegated {
+				s.Boost.Delegated = false
+				apply = true
+			}
+		})
+		if err != nil {
+			d.log.Errorf("Error while saving the state: %s", err.Error())
+			apply = false
+		}
+	}
+
+	d.mu.Lock()
+	if d.Metrics != nil {
+		// Outside the active hours the next transition is the upgrade
+		next, _ := d.Schedule.Next(now)
+		d.Metrics.NextTransition(d.ServerID, tierName(!d.Schedule.Active(now)), next)
+	}
+	if revert {
+		d.log.Infof("Boost expired, applying the schedule again")
+		if d.Schedule.Active(now) {
+			d.setPending(d.upgrade(SourceBoost))
+		} else {
+			d.setPending(d.downgrade(SourceBoost))
+		}
+	}
+	if apply {
+		d.log.Infof("Boost requested until %s", st.Boost.Until.Format("15:04"))
+		d.pending = &transition{top: true, tier: tier.Tier{Names: st.Boost.ServerTypes}, source: SourceBoost, doing: "boosting", done: "boosted", boost: true}
+	}
+	if boosted {
+		d.top = true
+	}
+
+	var scheduled *transition
+	if !paused {
+		// Every transition since the last tick, once, even if the ticks are late or woken up early
+		top, due := d.Schedule.Due(since, now)
+		switch {
+		case !due:
+		case !top:
+			scheduled = d.downgrade(SourceSchedule)
+		// With window bounds autoscale decides when to upgrade
+		case d.Autoscale == nil || d.AutoscaleBounds != BoundsWindow:
+			scheduled = d.upgrade(SourceSchedule)
+		}
+	}
+	d.mu.Unlock()
+
+	// The boost wins over the schedule, the skip is kept for the next transition
+	if scheduled != nil && boosted {
+		d.log.Warnf("Not %s the server, it is boosted until %s", scheduled.doing, st.Boost.Until.Format("15:04"))
+		scheduled = nil
+	}
+
+	if scheduled != nil && st.SkipNext {
+		if _, err := d.store().Update(func(s *state.State) { s.SkipNext = false }); err != nil {
+			d.log.Errorf("Error while saving the state: %s", err.Error())
+		}
+		d.log.Warnf("Skipped %s the server as requested", scheduled.doing)
+		// The server is meant to stay on its tier until the next transition
+		d.mu.Lock()
+		d.intend(!scheduled.top)
+		d.mu.Unlock()
+		scheduled = nil
+	}
+
+	d.mu.Lock()
+	// A pending transition to the same tier keeps its retry backoff and busy deferral
+	if scheduled != nil && (d.pending == nil || d.pending.boost || d.pending.top != scheduled.top) {
+		d.pending = scheduled
+	}
+	if scheduled != nil {
+		d.intend(scheduled.top)
+	}
+	evaluate := !paused && !boosted && d.pending == nil && d.autoscaleAllowed(now)
+	top := d.top
+	d.mu.Unlock()
+
+	// Metrics are read without holding the lock
+	if evaluate {
+		decision, reason, err := d.Autoscale.Evaluate(now, top)
+		if err != nil {
+			// Metrics may be temporarily missing, try again on the next tick
+			d.log.Warnf("Autoscale: %s", err.Error())
+		}
+
+		d.mu.Lock()
+		if d.pending == nil {
+			switch decision {
+			case autoscale.Upgrade:
+				d.log.Infof("Autoscale: %s", reason)
+				d.setPending(d.upgrade(SourceAutoscale))
+			case autoscale.Downgrade:
+				d.log.Infof("Autoscale: %s", reason)
+				d.setPending(d.downgrade(SourceAutoscale))
+			}
+		}
+		d.mu.Unlock()
+	}
+
+	if d.Watchdog != nil && !paused {
+		d.watch(now)
+	}
+
+	// Compare the server with the desired state once in a while
+	d.mu.Lock()
+	reconcile := !paused && d.reconcileDue(now)
+	d.mu.Unlock()
+	if reconcile {
+		d.reconcile(now, st)
+	}
+
+	d.mu.Lock()
+	t := d.pending
+	if t != nil && !t.boost && (paused || boosted) {
+		t = nil
+	}
+	if d.stopping {
+		t = nil
+	}
+	d.mu.Unlock()
+
+	if t == nil {
+		return nil
+	}
+	return d.rescale(now, t)
+}
+
+/* Read the persisted state, resuming the scheduling if the pause is over */
+func (d *Daemon) state(now time.Time) state.State {
+	st, err := d.store().Load()
+	if err != nil {
+		d.log.Errorf("Error while reading the state: %s", err.Error())
+		return st
+	}
+
+	if st.Paused && !st.IsPaused(now) {
+		st, err = d.store().Update(func(s *state.State) {
+			s.Resume()
+		})
+		if err != nil {
+			d.log.Errorf("Error while saving the state: %s", err.Error())
+		}
+		d.log.Infof("Scheduling resumed")
+	}
+	return st
+}
+
+/* Whether autoscale can change the tier at the given time */
+func (d *Daemon) autoscaleAllowed(now time.Time) bool {
+	if d.Autoscale == nil {
+		return false
+	}
+	if d.AutoscaleBounds == BoundsWindow {
+		return d.Schedule.Active(now)
+	}
+	return !d.Schedule.Active(now)
+}
+
+/* Whether the downgrade must wait because the server is busy */
+func (d *Daemon) deferred(now time.Time, t *transition) bool {
+	if d.Busy == nil || t.top {
+		return false
+	}
+
+	// The bookkeeping of the transition is shared with the control API
+	d.mu.Lock()
+	if now.Before(t.nextCheck) {
+		d.mu.Unlock()
+		return true
+	}
+	if t.deferredSince.IsZero() {
+		t.deferredSince = now
+	}
+	deferredFor := now.Sub(t.deferredSince)
+	d.mu.Unlock()
+
+	if deferredFor >= d.Busy.MaxDelay {
+		d.log.Warnf("Downgrade deferred for %s, the maximum delay has been reached", deferredFor.Round(time.Minute))
+		return false
+	}
+
+	reasons := d.Busy.Busy(now)
+	if len(reasons) == 0 {
+		return false
+	}
+
+	nextCheck := now.Add(d.Busy.Recheck)
+	d.mu.Lock()
+	t.nextCheck = nextCheck
+	d.mu.Unlock()
+	d.log.Warnf("Downgrade deferred, the server is busy (%s), checking again at %s (deferred for %s, maximum %s)",
+		strings.Join(reasons, ", "),
+		nextCheck.Format("15:04"),
+		deferredFor.Round(time.Minute),
+		d.Busy.MaxDelay,
+	)
+	return true
+}
+
+/* Run the transition, on failure it is kept pending and retried after a backoff */
+func (d *Daemon) rescale(now time.Time, t *transition) error {
+	d.mu.Lock()
+	waiting := now.Before(t.retryAt)
+	d.mu.Unlock()
+	if waiting || d.deferred(now, t) {
+		return nil
+	}
+
+	err := d.attempt(now, t)
+	if err != nil && !d.ErrorPolicy.Exit && !unrecoverable(err) {
+		retryAt := now.Add(d.failed(err))
+		d.mu.Lock()
+		t.retryAt = retryAt
+		d.mu.Unlock()
+		return nil
+	}
+	if err == nil {
+		d.recovered()
+	}
+	return err
+}
+
+/* Run the transition once */
+func (d *Daemon) attempt(now time.Time, t *transition) error {
+	// Update the server instance
+	if err := d.refresh(); err != nil {
+		return err
+	}
+	server := d.currentServer()
+
+	d.log.Infof("Start %s server...", t.doing)
+
+	// Requirement tiers are resolved to the server types available now
+	d.mu.Lock()
+	target := t.tier
+	d.mu.Unlock()
+	serverNames, reason, err := target.Resolve(d.Client, server)
+	if err != nil {
+		return fmt.Errorf("error while choosing the server type: %s", err.Error())
+	}
+	if reason != "" {
+		d.log.Infof("Chosen server type %s", reason)
+	}
+
+	opts := d.RescaleOptions
+	if d.Metrics != nil {
+		opts.Observer = d.Metrics.Observer(opts.Observer)
+	}
+
+	d.setRunning(t)
+	result, err := rescaler.Rescale(d.Client, server, serverNames, opts)
+	d.setRunning(nil)
+	if d.OnResult != nil {
+		d.OnResult(t.source, result)
+	}
+	if d.Metrics != nil {
+		d.Metrics.Rescale(d.ServerID, direction(t), result.Outcome)
+	}
+
+	// Keep the status and the metrics in line with the new type
+	if err == nil && !result.Skipped && !result.DryRun {
+		if err := d.refresh(); err != nil {
+			d.log.Warnf("%s", err.Error())
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(result.Phases) > 0 {
+		d.lastResult = result
+	}
+
+	switch {
+	case rescaler.IsPostponed(err):
+		d.log.Warnf("%s, retrying in a minute", err.Error())
+	case err != nil:
+		var preflightErr *rescaler.PreflightError
+		if !errors.As(err, &preflightErr) {
+			return fmt.Errorf("error while resizing server: %w", err)
+		}
+		// The server cannot be touched, skip this transition
+		d.log.Errorf("%s", err.Error())
+		d.done(t)
+	default:
+		d.log.Infof("Server successfully %s: %s", t.done, result)
+		d.top = t.top
+		d.done(t)
+		if d.Autoscale != nil && !result.Skipped {
+			d.Autoscale.Changed(now)
+		}
+	}
+
+	return nil
+}
+
+/* Finish or roll back an interrupted rescale */
+func (d *Daemon) recover(server *hcloud.Server) error {
+	result, err := rescaler.Recover(d.Client, server, d.RescaleOptions)
+	if result == nil {
+		return err
+	}
+	if d.OnResult != nil {
+		d.OnResult(SourceRecovery, result)
+	}
+	if err != nil {
+		return fmt.Errorf("error while recovering the interrupted rescale: %s", err.Error())
+	}
+
+	d.log.Infof("Interrupted rescale recovered: %s", result)
+	return d.refresh()
+}
+
+/* Make the transition the pending one, remembering its tier for the reconcile, requires the lock */
+func (d *Daemon) setPending(t *transition) {
+	d.pending = t
+	d.intend(t.top)
+}
+
+/* Remember the tier last asked for, requires the lock */
+func (d *Daemon) intend(top bool) {
+	d.intent = top
+	d.hasIntent = true
+}
+
+/* Clear the transition unless it has been replaced in the meantime, requires the lock */
+func (d *Daemon) done(t *transition) {
+	if d.pending == t {
+		d.pending = nil
+	}
+}
+
+func (d *Daemon) setRunning(t *transition) {
+	d.mu.Lock()
+	d.running = t
+	d.mu.Unlock()
+}
+
+/* Fetch the current state of the server */
+func (d *Daemon) refresh() error {
+	server, _, err := d.Client.Server.GetByID(context.Background(), d.ServerID)
+	if err != nil {
+		return fmt.Errorf("error while getting server: %w", err)
+	}
+	if server == nil {
+		return errServerNotFound
+	}
+
+	d.mu.Lock()
+	d.server = server
+	d.mu.Unlock()
+
+	if d.Metrics != nil {
+		d.Metrics.Server(server)
+	}
+	return nil
+}
+
+/* State store, in memory if none was given */
+func (d *Daemon) store() *state.Store {
+	d.stateOnce.Do(func() {
+		if d.State == nil {
+			d.State = state.Open("")
+		}
+	})
+	return d.State
+}
+
+func (d *Daemon) currentServer() *hcloud.Server {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.server
+}
+
+func (d *Daemon) upgrade(source string) *transition {
+	return &transition{top: true, tier: d.Schedule.Top, source: source, doing: "upgrading", done: "upgraded"}
+}
+
+func (d *Daemon) downgrade(source string) *transition {
+	return &transition{top: false, tier: d.Schedule.Base, source: source, doing: "downgrading", done: "downgraded"}
+}
+
+func direction(t *transition) string {
+	if t.top {
+		return exporter.DirectionUp
+	}
+	return exporter.DirectionDown
+}
@@ -0,0 +1,107 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jonamat/hetzner-rescaler/pkg/notify"
+)
+
+/* What the daemon does when a transition or an API call fails */
+type ErrorPolicy struct {
+	// Exit on the first error instead of retrying
+	Exit bool
+	// Delay before the first retry, doubled on each failure up to RetryMax, 1m and 30m if zero
+	RetryMin time.Duration
+	RetryMax time.Duration
+}
+
+/* The server to rescale does not exist */
+var errServerNotFound = errors.New("server not found")
+
+/* Whether retrying cannot fix the error: wrong token, server or server type */
+func unrecoverable(err error) bool {
+	if errors.Is(err, errServerNotFound) {
+		return true
+	}
+
+	var apiErr hcloud.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.Code {
+	case "unauthorized", hcloud.ErrorCodeForbidden, hcloud.ErrorCodeNotFound, hcloud.ErrorCodeInvalidServerType:
+		return true
+	}
+	return false
+}
+
+/* Mark the server degraded and return the delay before the next attempt */
+func (d *Daemon) failed(err error) time.Duration {
+	d.mu.Lock()
+	d.failures++
+	d.lastError = err.Error()
+	failures := d.failures
+	d.mu.Unlock()
+
+	retryMin, retryMax := d.ErrorPolicy.RetryMin, d.ErrorPolicy.RetryMax
+	if retryMin <= 0 {
+		retryMin = time.Minute
+	}
+	if retryMax < retryMin {
+		retryMax = time.Minute * 30
+	}
+	delay := retryMin
+	for i := 1; i < failures && delay < retryMax; i++ {
+		delay *= 2
+	}
+	if delay > retryMax {
+		delay = retryMax
+	}
+
+	d.log.Errorf("%s, retrying in %s (failure %d)", err.Error(), delay, failures)
+	if d.Metrics != nil {
+		d.Metrics.Degraded(d.ServerID, true)
+	}
+	if failures == 1 {
+		d.alert(notify.EventDegraded, notify.SeverityCritical, fmt.Sprintf("%s, retrying in %s", err.Error(), delay))
+	}
+	return delay
+}
+
+/* Clear the degraded state after a success */
+func (d *Daemon) recovered() {
+	d.mu.Lock()
+	failures := d.failures
+	d.failures = 0
+	d.lastError = ""
+	d.mu.Unlock()
+
+	if failures == 0 {
+		return
+	}
+	d.log.Infof("Recovered after %d failures", failures)
+	if d.Metrics != nil {
+		d.Metrics.Degraded(d.ServerID, false)
+	}
+	d.alert(notify.EventRecovered, notify.SeverityResolved, fmt.Sprintf("Recovered after %d failures", failures))
+}
+
+/* Send an alert in the background, if a notifier is configured */
+func (d *Daemon) alert(event, severity, message string) {
+	if d.Notifier == nil {
+		return
+	}
+
+	a := notify.Alert{Event: event, Severity: severity, ServerID: d.ServerID, Message: message, Time: time.Now()}
+	if server := d.currentServer(); server != nil {
+		a.ServerName = server.Name
+	}
+	go func() {
+		if err := d.Notifier.Notify(a); err != nil {
+			d.log.Warnf("%s", err.Error())
+		}
+	}()
+}
@@ -0,0 +1,150 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jonamat/hetzner-rescaler/pkg/notify"
+	"github.com/jonamat/hetzner-rescaler/pkg/state"
+	"github.com/jonamat/hetzner-rescaler/pkg/tier"
+)
+
+/* What the reconcile does when the server drifts from the desired state */
+const (
+	// Log and alert only
+	ReconcileAlert = "alert"
+	// Rescale or power on the server back to the desired state
+	ReconcileCorrect = "correct"
+)
+
+/* Periodic comparison of the server with the state the daemon wants */
+type Reconcile struct {
+	// ReconcileAlert or ReconcileCorrect
+	Policy   string
+	Interval time.Duration
+}
+
+/* Whether the reconcile must run now, requires the lock */
+func (d *Daemon) reconcileDue(now time.Time) bool {
+	if d.Reconcile == nil || d.stopping || d.pending != nil || d.running != nil {
+		return false
+	}
+	if now.Sub(d.lastReconcile) < d.Reconcile.Interval {
+		return false
+	}
+	d.lastReconcile = now
+	return true
+}
+
+/* Compare the type and the power state of the server with the desired ones, correcting them if the policy says so */
+func (d *Daemon) reconcile(now time.Time, st state.State) {
+	if err := d.refresh(); err != nil {
+		d.log.Warnf("Reconcile: %s", err.Error())
+		return
+	}
+	server := d.currentServer()
+
+	// The tier of the last transition asked for or skipped, the schedule if there was none, unless a boost runs
+	d.mu.Lock()
+	top := d.Schedule.Active(now)
+	if d.autoscaleAllowed(now) {
+		top = d.top
+	}
+	if d.hasIntent {
+		top = d.intent
+	}
+	desired := d.Schedule.Base
+	if top {
+		desired = d.Schedule.Top
+	}
+	d.mu.Unlock()
+	if st.Boosted(now) {
+		top = true
+		desired = tier.Tier{Names: st.Boost.ServerTypes}
+	}
+
+	names, _, err := desired.Resolve(d.Client, server)
+	if err != nil {
+		d.log.Warnf("Reconcile: error while choosing the server type: %s", err.Error())
+		return
+	}
+
+	var drifts []string
+	typeDrift := !tier.Tier{Names: names}.Contains(server.ServerType.Name)
+	if typeDrift {
+		drifts = append(drifts, fmt.Sprintf("server type is %s instead of %s", server.ServerType.Name, desired))
+	}
+	// The watchdog takes care of the power state if enabled
+	powerDrift := d.Watchdog == nil && server.Status == hcloud.ServerStatusOff
+	if powerDrift {
+		drifts = append(drifts, "server is off")
+	}
+	drift := strings.Join(drifts, ", ")
+
+	d.mu.Lock()
+	previous := d.drift
+	d.drift = drift
+	d.mu.Unlock()
+	if d.Metrics != nil {
+		d.Metrics.Drift(d.ServerID, drift != "")
+	}
+
+	if drift == "" {
+		if previous != "" {
+			d.log.Infof("Drift resolved")
+			d.alert(notify.EventDrift, notify.SeverityResolved, "The server matches the desired state again")
+		}
+		return
+	}
+
+	if d.Reconcile.Policy != ReconcileCorrect {
+		d.log.Warnf("Drift detected: %s", drift)
+		if drift != previous {
+			d.alert(notify.EventDrift, notify.SeverityWarning, "Drift detected: "+drift)
+		}
+		return
+	}
+
+	d.log.Warnf("Drift detected: %s, correcting it", drift)
+	if drift != previous {
+		d.alert(notify.EventDrift, notify.SeverityWarning, fmt.Sprintf("Drift detected: %s, correcting it", drift))
+	}
+
+	// The rescale starts the server too
+	if typeDrift {
+		d.mu.Lock()
+		t := d.downgrade(SourceReconcile)
+		if top {
+			t = d.upgrade(SourceReconcile)
+		}
+		t.tier = desired
+		t.boost = st.Boosted(now)
+		if d.pending == nil {
+			d.pending = t
+		}
+		d.mu.Unlock()
+		return
+	}
+
+	if err := d.powerOn(server); err != nil {
+		d.log.Errorf("Error while starting the server: %s", err.Error())
+	}
+}
+
+/* Start the server without waiting for it to be running */
+func (d *Daemon) powerOn(server *hcloud.Server) error {
+	if d.RescaleOptions.DryRun {
+		d.log.Infof("[dry-run] POST /servers/%d/actions/poweron", server.ID)
+		return nil
+	}
+
+	action, _, err := d.Client.Server.Poweron(context.Background(), server)
+	if err != nil {
+		return err
+	}
+	d.log.Infof("Starting the server (action %d)", action.ID)
+	return nil
+}
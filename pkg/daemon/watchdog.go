@@ -0,0 +1,83 @@
+package daemon
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jonamat/hetzner-rescaler/pkg/notify"
+)
+
+/* Checks on every tick that the server is not off outside of the rescales */
+type Watchdog struct {
+	// Start the server again, otherwise only alert
+	PowerOn bool
+	// How long the server must be off before the watchdog acts
+	Grace time.Duration
+}
+
+/* Alert, and power on if allowed, when the server stays off while no transition is pending */
+func (d *Daemon) watch(now time.Time) {
+	d.mu.Lock()
+	idle := d.pending == nil && d.running == nil && !d.stopping
+	if !idle {
+		// The server may be off because of the transition
+		d.offSince = time.Time{}
+	}
+	d.mu.Unlock()
+	if !idle {
+		return
+	}
+
+	if err := d.refresh(); err != nil {
+		d.log.Warnf("Watchdog: %s", err.Error())
+		return
+	}
+	server := d.currentServer()
+
+	if server.Status != hcloud.ServerStatusOff {
+		d.mu.Lock()
+		alerted := d.offAlerted
+		d.offSince = time.Time{}
+		d.offAlerted = false
+		d.mu.Unlock()
+
+		if alerted {
+			d.log.Infof("Server is %s again", server.Status)
+			d.alert(notify.EventServerOff, notify.SeverityResolved, fmt.Sprintf("The server is %s again", server.Status))
+		}
+		return
+	}
+
+	d.mu.Lock()
+	if d.offSince.IsZero() {
+		d.offSince = now
+	}
+	offSince := d.offSince
+	if now.Sub(offSince) < d.Watchdog.Grace {
+		d.mu.Unlock()
+		return
+	}
+	alert := !d.offAlerted
+	d.offAlerted = true
+	// Started again at most once per grace period, the server may take a while to boot
+	powerOn := d.Watchdog.PowerOn && now.Sub(d.poweredOnAt) >= d.Watchdog.Grace
+	if powerOn {
+		d.poweredOnAt = now
+	}
+	d.mu.Unlock()
+
+	if alert {
+		message := fmt.Sprintf("The server is unexpectedly off since %s", offSince.Format("15:04"))
+		if d.Watchdog.PowerOn {
+			message += ", starting it"
+		}
+		d.log.Warnf("%s", message)
+		d.alert(notify.EventServerOff, notify.SeverityCritical, message)
+	}
+	if powerOn {
+		if err := d.powerOn(server); err != nil {
+			d.log.Errorf("Error while starting the server: %s", err.Error())
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package exporter
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jonamat/hetzner-rescaler/pkg/pricing"
+	"github.com/jonamat/hetzner-rescaler/pkg/rescaler"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+/* Direction of a rescale */
+const (
+	DirectionUp   = "up"
+	DirectionDown = "down"
+)
+
+/* Prometheus metrics of the daemon */
+type Exporter struct {
+	registry       *prometheus.Registry
+	rescales       *prometheus.CounterVec
+	phases         *prometheus.HistogramVec
+	downtime       *prometheus.HistogramVec
+	serverType     *prometheus.GaugeVec
+	price          *prometheus.GaugeVec
+	apiErrors      *prometheus.CounterVec
+	nextTransition *prometheus.GaugeVec
+	degraded       *prometheus.GaugeVec
+	drift          *prometheus.GaugeVec
+}
+
+// Rescale phases take from a few seconds to several minutes
+var durationBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1200}
+
+func New() *Exporter {
+	e := &Exporter{
+		registry: prometheus.NewRegistry(),
+		rescales: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "hetzner_rescaler_rescales_total",
+			Help: "Rescales by direction and outcome.",
+		}, []string{"server_id", "direction", "outcome"}),
+		phases: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "hetzner_rescaler_phase_duration_seconds",
+			Help:    "Duration of the rescale phases.",
+			Buckets: durationBuckets,
+		}, []string{"server_id", "phase"}),
+		downtime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "hetzner_rescaler_downtime_seconds",
+			Help:    "Time the server was not running during a rescale.",
+			Buckets: durationBuckets,
+		}, []string{"server_id"}),
+		serverType: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "hetzner_rescaler_server_type",
+			Help: "Current server type, always 1.",
+		}, []string{"server_id", "server_type"}),
+		price: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "hetzner_rescaler_server_price_hourly",
+			Help: "Gross hourly price of the current server type.",
+		}, []string{"server_id", "currency"}),
+		apiErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "hetzner_rescaler_api_errors_total",
+			Help: "Failed requests to the Hetzner API by status code, 0 if no response was received.",
+		}, []string{"code"}),
+		nextTransition: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "hetzner_rescaler_next_transition_timestamp_seconds",
+			Help: "Unix time of the next scheduled transition.",
+		}, []string{"server_id", "tier"}),
+		degraded: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "hetzner_rescaler_degraded",
+			Help: "1 while the daemon keeps failing and retries, 0 otherwise.",
+		}, []string{"server_id"}),
+		drift: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "hetzner_rescaler_drift",
+			Help: "1 while the server differs from the desired state, 0 otherwise.",
+		}, []string{"server_id"}),
+	}
+
+	e.registry.MustRegister(e.rescales, e.phases, e.downtime, e.serverType, e.price, e.apiErrors, e.nextTransition, e.degraded, e.drift)
+	return e
+}
+
+/* Serve the metrics in the Prometheus format */
+func (e *Exporter) Handler() http.Handler {
+	return promhttp.HandlerFor(e.registry, promhttp.HandlerOpts{})
+}
+
+/* Count a finished rescale, outcome is one of the rescaler.Outcome constants */
+func (e *Exporter) Rescale(serverID int, direction, outcome string) {
+	e.rescales.WithLabelValues(strconv.Itoa(serverID), direction, outcome).Inc()
+}
+
+/* Record the current type and price of the server */
+func (e *Exporter) Server(server *hcloud.Server) {
+	id := strconv.Itoa(server.ID)
+
+	// A single server is watched, drop the previous type
+	e.serverType.Reset()
+	e.serverType.WithLabelValues(id, server.ServerType.Name).Set(1)
+
+	e.price.Reset()
+	if hourly, _, currency, ok := pricing.ServerTypePrice(server.ServerType, pricing.ServerLocation(server)); ok {
+		e.price.WithLabelValues(id, currency).Set(hourly)
+	}
+}
+
+/* Record the next scheduled transition */
+func (e *Exporter) NextTransition(serverID int, tier string, t time.Time) {
+	e.nextTransition.Reset()
+	e.nextTransition.WithLabelValues(strconv.Itoa(serverID), tier).Set(float64(t.Unix()))
+}
+
+/* Record whether the daemon is degraded */
+func (e *Exporter) Degraded(serverID int, degraded bool) {
+	var value float64
+	if degraded {
+		value = 1
+	}
+	e.degraded.WithLabelValues(strconv.Itoa(serverID)).Set(value)
+}
+
+/* Record whether the server drifted from the desired state */
+func (e *Exporter) Drift(serverID int, drift bool) {
+	var value float64
+	if drift {
+		value = 1
+	}
+	e.drift.WithLabelValues(strconv.Itoa(serverID)).Set(value)
+}
+
+/* Observer recording the phase durations and the downtime, forwarding the events to next if not nil */
+func (e *Exporter) Observer(next rescaler.Observer) rescaler.Observer {
+	return rescaler.ObserverFunc(func(ev rescaler.Event) {
+		id := strconv.Itoa(ev.ServerID)
+		switch ev.Type {
+		case rescaler.EventPhaseFinished:
+			e.phases.WithLabelValues(id, ev.Phase).Observe(ev.Duration.Seconds())
+		case rescaler.EventRescaleFinished:
+			if ev.Result != nil && len(ev.Result.Phases) > 0 && !ev.Result.DryRun {
+				e.downtime.WithLabelValues(id).Observe(ev.Result.Downtime.Seconds())
+			}
+		}
+
+		if next != nil {
+			next.OnEvent(ev)
+		}
+	})
+}
+
+/* HTTP transport counting the failed requests, to be used by the Hetzner API client */
+func (e *Exporter) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		resp, err := next.RoundTrip(r)
+		switch {
+		case err != nil:
+			e.apiErrors.WithLabelValues("0").Inc()
+		case resp.StatusCode >= 400:
+			e.apiErrors.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
+		}
+		return resp, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
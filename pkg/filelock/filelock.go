@@ -0,0 +1,19 @@
+package filelock
+
+import "os"
+
+/* Block until the lock file at path is taken exclusively, across processes, the returned func releases it */
+func Lock(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,184 @@
+package history
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jonamat/hetzner-rescaler/pkg/rescaler"
+)
+
+/* A rescale attempt, with what asked for it */
+type Entry struct {
+	// Schedule, autoscale, api, boost or the command which ran the rescale
+	Source string `json:"source"`
+	rescaler.Result
+}
+
+/* Append-only JSON lines file of the rescale attempts */
+type Journal struct {
+	path string
+	mu   sync.Mutex
+}
+
+/* Journal stored in the history.jsonl file of the given directory */
+func Open(dir string) *Journal {
+	return &Journal{path: filepath.Join(dir, "history.jsonl")}
+}
+
+/* Add an entry at the end of the journal */
+func (j *Journal) Append(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	f, err := os.OpenFile(j.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+/* Selection of the entries, zero values match everything */
+type Filter struct {
+	ServerID int
+	Since    time.Time
+	Until    time.Time
+}
+
+func (f Filter) match(e Entry) bool {
+	if f.ServerID != 0 && e.ServerID != f.ServerID {
+		return false
+	}
+	if !f.Since.IsZero() && e.Start.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Start.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+/* Entries matching the filter, oldest first */
+func (j *Journal) Read(filter Filter) ([]Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	f, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s line %d: %s", j.path, line, err.Error())
+		}
+		if filter.match(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+/* Write the entries as a JSON array */
+func WriteJSON(w io.Writer, entries []Entry) error {
+	if entries == nil {
+		entries = []Entry{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entries)
+}
+
+// Phases exported as CSV columns, in order
+var csvPhases = []string{
+	rescaler.PhaseShutdown,
+	rescaler.PhaseWaitOff,
+	rescaler.PhaseChangeType,
+	rescaler.PhasePowerOn,
+	rescaler.PhaseHealthCheck,
+}
+
+/* Write the entries as CSV, one row per entry with the phase durations in seconds */
+func WriteCSV(w io.Writer, entries []Entry) error {
+	writer := csv.NewWriter(w)
+
+	header := []string{"start", "end", "server_id", "source", "outcome", "from_type", "to_type", "dry_run", "downtime_seconds"}
+	for _, phase := range csvPhases {
+		header = append(header, phase+"_seconds")
+	}
+	header = append(header, "error")
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		row := []string{
+			e.Start.Format(time.RFC3339),
+			e.End.Format(time.RFC3339),
+			strconv.Itoa(e.ServerID),
+			e.Source,
+			e.Outcome,
+			e.FromType,
+			e.ToType,
+			strconv.FormatBool(e.DryRun),
+			seconds(e.Downtime),
+		}
+		for _, phase := range csvPhases {
+			row = append(row, phaseSeconds(e.Result, phase))
+		}
+		row = append(row, e.Error)
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+/* Total time spent in the phase, empty if the phase did not run */
+func phaseSeconds(r rescaler.Result, name string) string {
+	var total time.Duration
+	found := false
+	for _, p := range r.Phases {
+		if p.Name == name {
+			total += p.Duration()
+			found = true
+		}
+	}
+	if !found {
+		return ""
+	}
+	return seconds(total)
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 0, 64)
+}
@@ -0,0 +1,113 @@
+package lease
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jonamat/hetzner-rescaler/pkg/filelock"
+)
+
+/* Lease backed by a lock file, for instances sharing the same data dir */
+type FileLease struct {
+	path     string
+	serverID int
+	self     Holder
+}
+
+/* Lease of the server stored in the lease-<id>.json file of dir */
+func NewFileLease(dir string, serverID int, self Holder) *FileLease {
+	return &FileLease{
+		path:     filepath.Join(dir, fmt.Sprintf("lease-%d.json", serverID)),
+		serverID: serverID,
+		self:     self,
+	}
+}
+
+func (l *FileLease) Acquire(ttl time.Duration) error {
+	// Two instances seeing an expired lease must not both take it over
+	unlock, err := filelock.Lock(l.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	holder := l.self
+	holder.Expires = time.Now().Add(ttl)
+	data, err := json.Marshal(holder)
+	if err != nil {
+		return err
+	}
+
+	// Two attempts: the second one after removing an expired lease
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(l.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			_, err = f.Write(data)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			return err
+		}
+		if !os.IsExist(err) {
+			return err
+		}
+
+		current, err := l.read()
+		if err != nil {
+			return err
+		}
+		switch {
+		case current.same(l.self):
+			// Renewal, written aside and renamed so readers never see a partial file
+			if err := os.WriteFile(l.path+".tmp", data, 0600); err != nil {
+				return err
+			}
+			return os.Rename(l.path+".tmp", l.path)
+		case time.Now().After(current.Expires):
+			// Left behind by a crashed instance
+			if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		default:
+			return &HeldError{ServerID: l.serverID, Holder: current}
+		}
+	}
+
+	return fmt.Errorf("could not acquire the lease %s", l.path)
+}
+
+func (l *FileLease) Release() error {
+	unlock, err := filelock.Lock(l.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, err := l.read()
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !current.same(l.self) {
+		return nil
+	}
+	return os.Remove(l.path)
+}
+
+func (l *FileLease) read() (Holder, error) {
+	var h Holder
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return h, err
+	}
+	// An unreadable lease is treated as expired
+	if err := json.Unmarshal(data, &h); err != nil {
+		return Holder{}, nil
+	}
+	return h, nil
+}
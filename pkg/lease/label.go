@@ -0,0 +1,142 @@
+package lease
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+)
+
+/* Label of the server holding the lease */
+const LabelKey = "hetzner-rescaler/lease"
+
+/* Lease stored as a label on the server itself, for instances on different hosts */
+type LabelLease struct {
+	client   *hcloud.Client
+	serverID int
+	self     Holder
+	// Time to wait before checking that no other instance overwrote the label
+	settle time.Duration
+}
+
+/* Lease of the server stored in its LabelKey label */
+func NewLabelLease(client *hcloud.Client, serverID int, self Holder) *LabelLease {
+	return &LabelLease{client: client, serverID: serverID, self: self, settle: time.Second * 2}
+}
+
+func (l *LabelLease) Acquire(ttl time.Duration) error {
+	server, err := l.server()
+	if err != nil {
+		return err
+	}
+
+	current, ok := parseLabel(server.Labels[LabelKey])
+	if ok && !sameLabel(current, l.self) && time.Now().Before(current.Expires) {
+		return &HeldError{ServerID: l.serverID, Holder: current}
+	}
+
+	holder := l.self
+	holder.Expires = time.Now().Add(ttl)
+	labels := copyLabels(server.Labels)
+	labels[LabelKey] = formatLabel(holder)
+	if _, _, err := l.client.Server.Update(context.Background(), server, hcloud.ServerUpdateOpts{Labels: labels}); err != nil {
+		return err
+	}
+
+	// Labels cannot be updated conditionally, check no other instance wrote at the same time
+	if ok && sameLabel(current, l.self) {
+		return nil
+	}
+	time.Sleep(l.settle)
+	server, err = l.server()
+	if err != nil {
+		return err
+	}
+	if written, ok := parseLabel(server.Labels[LabelKey]); ok && !sameLabel(written, l.self) {
+		return &HeldError{ServerID: l.serverID, Holder: written}
+	}
+	return nil
+}
+
+func (l *LabelLease) Release() error {
+	server, err := l.server()
+	if err != nil {
+		return err
+	}
+	current, ok := parseLabel(server.Labels[LabelKey])
+	if !ok || !sameLabel(current, l.self) {
+		return nil
+	}
+
+	labels := copyLabels(server.Labels)
+	delete(labels, LabelKey)
+	_, _, err = l.client.Server.Update(context.Background(), server, hcloud.ServerUpdateOpts{Labels: labels})
+	return err
+}
+
+func (l *LabelLease) server() (*hcloud.Server, error) {
+	server, _, err := l.client.Server.GetByID(context.Background(), l.serverID)
+	if err != nil {
+		return nil, err
+	}
+	if server == nil {
+		return nil, fmt.Errorf("server %d not found", l.serverID)
+	}
+	return server, nil
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	copied := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		copied[k] = v
+	}
+	return copied
+}
+
+// Characters not allowed in a label value
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+func sanitize(s string) string {
+	return invalidLabelChars.ReplaceAllString(s, "-")
+}
+
+/* Label values are limited to 63 characters: command.host.pid.expiry */
+func formatLabel(h Holder) string {
+	suffix := fmt.Sprintf(".%d.%d", h.PID, h.Expires.Unix())
+	prefix := sanitize(h.Command) + "." + sanitize(h.Host)
+	if max := 63 - len(suffix); len(prefix) > max {
+		prefix = prefix[:max]
+	}
+	return strings.Trim(prefix, "-.") + suffix
+}
+
+/* Whether both holders are the same process, as far as the label value tells */
+func sameLabel(a, b Holder) bool {
+	a.Expires = b.Expires
+	return formatLabel(a) == formatLabel(b)
+}
+
+func parseLabel(value string) (Holder, bool) {
+	parts := strings.Split(value, ".")
+	if len(parts) < 4 {
+		return Holder{}, false
+	}
+	pid, err := strconv.Atoi(parts[len(parts)-2])
+	if err != nil {
+		return Holder{}, false
+	}
+	expires, err := strconv.ParseInt(parts[len(parts)-1], 10, 64)
+	if err != nil {
+		return Holder{}, false
+	}
+	return Holder{
+		Command: parts[0],
+		Host:    strings.Join(parts[1:len(parts)-2], "."),
+		PID:     pid,
+		Expires: time.Unix(expires, 0),
+	}, true
+}
@@ -0,0 +1,80 @@
+package lease
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+/* Exclusive right of one instance to rescale a server, until it expires */
+type Lease interface {
+	// Take the lease or extend it, a *HeldError is returned if another instance holds it
+	Acquire(ttl time.Duration) error
+	// Give the lease up if it is held by this instance
+	Release() error
+}
+
+/* Instance holding a lease */
+type Holder struct {
+	// Command run by the instance, eg. start
+	Command string    `json:"command"`
+	Host    string    `json:"host"`
+	PID     int       `json:"pid"`
+	Expires time.Time `json:"expires"`
+}
+
+/* This process, running the given command */
+func Self(command string) Holder {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return Holder{Command: command, Host: host, PID: os.Getpid()}
+}
+
+func (h Holder) String() string {
+	return fmt.Sprintf("%s on %s (pid %d) until %s", h.Command, h.Host, h.PID, h.Expires.Local().Format("2006-01-02 15:04:05"))
+}
+
+/* Whether both holders are the same process */
+func (h Holder) same(o Holder) bool {
+	return h.Host == o.Host && h.PID == o.PID
+}
+
+/* The lease is held by another instance */
+type HeldError struct {
+	ServerID int
+	Holder   Holder
+}
+
+func (e *HeldError) Error() string {
+	return fmt.Sprintf("server %d is leased by %s", e.ServerID, e.Holder)
+}
+
+/* Renew the lease every third of its ttl until stopped, lost is called if the renewal fails */
+func Keep(l Lease, ttl time.Duration, lost func(error)) (stop func()) {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := l.Acquire(ttl); err != nil {
+					lost(err)
+				}
+			}
+		}
+	}()
+
+	// A renewal in progress would write the lease again after the release
+	return func() {
+		close(done)
+		<-exited
+		l.Release()
+	}
+}
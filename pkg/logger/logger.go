@@ -0,0 +1,283 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+)
+
+/* Severity of a log line */
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	}
+	return "error"
+}
+
+/* Level from its name */
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "", "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("invalid log level %q, expected debug, info, warn or error", s)
+}
+
+/* Output formats */
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+/* Field names shared by all the packages */
+const (
+	FieldServerID   = "server_id"
+	FieldServerName = "server_name"
+	FieldPhase      = "phase"
+	FieldActionID   = "action_id"
+	FieldFromType   = "from_type"
+	FieldToType     = "to_type"
+)
+
+/* Structured fields attached to the log lines */
+type Fields map[string]interface{}
+
+/* Leveled logger writing text or JSON lines */
+type Logger struct {
+	settings *settings
+	sink     *sink
+	fields   Fields
+}
+
+/* Format and level, shared by the loggers derived from the same root */
+type settings struct {
+	mu     sync.RWMutex
+	format string
+	level  Level
+}
+
+/* Destination of the lines, colored only if it is a terminal */
+type sink struct {
+	mu    sync.Mutex
+	w     io.Writer
+	color bool
+}
+
+var std = New(os.Stderr, FormatText, LevelInfo)
+
+/* Logger writing to w, with colors if w is a terminal */
+func New(w io.Writer, format string, level Level) *Logger {
+	return &Logger{
+		settings: &settings{format: format, level: level},
+		sink:     newSink(w),
+	}
+}
+
+func newSink(w io.Writer) *sink {
+	return &sink{w: w, color: isTerminal(w)}
+}
+
+/* Colors are used only on terminals, unless disabled with NO_COLOR */
+func isTerminal(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+/* Logger used by all the packages */
+func Default() *Logger {
+	return std
+}
+
+/* Change the format and level of the default logger and of the loggers derived from it */
+func Configure(format string, level Level) error {
+	if format != FormatText && format != FormatJSON {
+		return fmt.Errorf("invalid log format %q, expected text or json", format)
+	}
+
+	std.settings.mu.Lock()
+	defer std.settings.mu.Unlock()
+	std.settings.format = format
+	std.settings.level = level
+	return nil
+}
+
+/* Logger adding the fields to every line */
+func (l *Logger) With(fields Fields) *Logger {
+	merged := make(Fields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &Logger{settings: l.settings, sink: l.sink, fields: merged}
+}
+
+/* Logger writing to w, keeping the format, level and fields */
+func (l *Logger) WithOutput(w io.Writer) *Logger {
+	return &Logger{settings: l.settings, sink: newSink(w), fields: l.fields}
+}
+
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.log(LevelDebug, format, args...)
+}
+
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.log(LevelInfo, format, args...)
+}
+
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.log(LevelWarn, format, args...)
+}
+
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.log(LevelError, format, args...)
+}
+
+func (l *Logger) log(level Level, format string, args ...interface{}) {
+	l.settings.mu.RLock()
+	minLevel, outputFormat := l.settings.level, l.settings.format
+	l.settings.mu.RUnlock()
+
+	if level < minLevel {
+		return
+	}
+
+	now := time.Now()
+	msg := strings.TrimRight(fmt.Sprintf(format, args...), "\n")
+
+	var line []byte
+	if outputFormat == FormatJSON {
+		line = l.json(now, level, msg)
+	} else {
+		line = l.text(now, level, msg)
+	}
+
+	l.sink.mu.Lock()
+	defer l.sink.mu.Unlock()
+	l.sink.w.Write(line)
+}
+
+func (l *Logger) json(now time.Time, level Level, msg string) []byte {
+	entry := make(map[string]interface{}, len(l.fields)+3)
+	for k, v := range l.fields {
+		// Errors have no exported fields
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		entry[k] = v
+	}
+	entry["time"] = now.Format(time.RFC3339)
+	entry["level"] = level.String()
+	entry["msg"] = msg
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		line, _ = json.Marshal(map[string]string{"time": now.Format(time.RFC3339), "level": level.String(), "msg": msg})
+	}
+	return append(line, '\n')
+}
+
+func (l *Logger) text(now time.Time, level Level, msg string) []byte {
+	var b strings.Builder
+	b.WriteString(now.Format("2006/01/02 15:04:05 "))
+	b.WriteString(l.paint(level, fmt.Sprintf("%-5s", strings.ToUpper(level.String()))))
+	b.WriteString(" ")
+	b.WriteString(msg)
+
+	keys := make([]string, 0, len(l.fields))
+	for k := range l.fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		value := fmt.Sprint(l.fields[k])
+		if strings.ContainsAny(value, " \"=") || value == "" {
+			value = strconv.Quote(value)
+		}
+		b.WriteString(" ")
+		b.WriteString(l.paint(LevelDebug, k+"="))
+		b.WriteString(value)
+	}
+
+	b.WriteString("\n")
+	return []byte(b.String())
+}
+
+func (l *Logger) paint(level Level, s string) string {
+	var c *color.Color
+	switch level {
+	case LevelDebug:
+		c = color.New(color.Faint)
+	case LevelInfo:
+		c = color.New(color.FgGreen)
+	case LevelWarn:
+		c = color.New(color.FgYellow)
+	default:
+		c = color.New(color.FgRed)
+	}
+
+	if l.sink.color {
+		c.EnableColor()
+	} else {
+		c.DisableColor()
+	}
+	return c.Sprint(s)
+}
+
+/* Shorthands for the default logger */
+
+func With(fields Fields) *Logger {
+	return std.With(fields)
+}
+
+func Debugf(format string, args ...interface{}) {
+	std.Debugf(format, args...)
+}
+
+func Infof(format string, args ...interface{}) {
+	std.Infof(format, args...)
+}
+
+func Warnf(format string, args ...interface{}) {
+	std.Warnf(format, args...)
+}
+
+func Errorf(format string, args ...interface{}) {
+	std.Errorf(format, args...)
+}
@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"strings"
+	"time"
+)
+
+/* Severity of an alert */
+const (
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+	// Sent when the problem of a previous alert is gone
+	SeverityResolved = "resolved"
+)
+
+/* What the alert is about */
+const (
+	// A transition or an API call keeps failing
+	EventDegraded = "degraded"
+	// The daemon works again after being degraded
+	EventRecovered = "recovered"
+	// The server type or power state differs from the desired one
+	EventDrift = "drift"
+	// The server is off while no rescale is running
+	EventServerOff = "server_off"
+)
+
+/* Something the operator should know about */
+type Alert struct {
+	Event      string    `json:"event"`
+	Severity   string    `json:"severity"`
+	ServerID   int       `json:"server_id"`
+	ServerName string    `json:"server_name,omitempty"`
+	Message    string    `json:"message"`
+	Time       time.Time `json:"time"`
+}
+
+/* Channel delivering the alerts */
+type Notifier interface {
+	Notify(a Alert) error
+}
+
+/* Notifier sending the alerts to all the channels, the errors are joined */
+type Multi []Notifier
+
+func (m Multi) Notify(a Alert) error {
+	var errs []string
+	for _, n := range m {
+		if err := n.Notify(a); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return &Error{Errors: errs}
+	}
+	return nil
+}
+
+/* Delivery failed on some of the channels */
+type Error struct {
+	Errors []string
+}
+
+func (e *Error) Error() string {
+	return "error while sending the alert: " + strings.Join(e.Errors, "; ")
+}
@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+/* Notifier posting the alerts as JSON to an URL */
+type Webhook struct {
+	URL string
+	// Sent as Authorization header if not empty, eg. "Bearer abc123"
+	Authorization string
+	Timeout       time.Duration
+}
+
+func (w *Webhook) Notify(a Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Authorization != "" {
+		req.Header.Set("Authorization", w.Authorization)
+	}
+
+	timeout := w.Timeout
+	if timeout == 0 {
+		timeout = time.Second * 10
+	}
+	resp, err := (&http.Client{Timeout: timeout}).Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s answered %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
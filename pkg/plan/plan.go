@@ -0,0 +1,144 @@
+package plan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jonamat/hetzner-rescaler/pkg/pricing"
+	"github.com/jonamat/hetzner-rescaler/pkg/schedule"
+	"github.com/jonamat/hetzner-rescaler/pkg/tier"
+)
+
+/* Rough downtime of a rescale: shutdown, provisioner wait, change type and power on */
+const EstimatedDowntime = 3 * time.Minute
+
+/* Snapshot of the server state the plan was computed from */
+type ServerState struct {
+	ServerType string `json:"server_type"`
+	Status     string `json:"status"`
+	Locked     bool   `json:"locked"`
+}
+
+/* Planned change for a single server */
+type Plan struct {
+	CreatedAt        time.Time     `json:"created_at"`
+	ServerID         int           `json:"server_id"`
+	ServerName       string        `json:"server_name"`
+	State            ServerState   `json:"state"`
+	TargetTypes      []string      `json:"target_types"`
+	Reason           string        `json:"reason,omitempty"`
+	ExpectedDowntime time.Duration `json:"expected_downtime"`
+	Currency         string        `json:"currency,omitempty"`
+	PriceHourlyFrom  float64       `json:"price_hourly_from"`
+	PriceHourlyTo    float64       `json:"price_hourly_to"`
+	PriceMonthlyFrom float64       `json:"price_monthly_from"`
+	PriceMonthlyTo   float64       `json:"price_monthly_to"`
+}
+
+/* Compare the current server state with what the schedule wants at the given time */
+func Build(client *hcloud.Client, server *hcloud.Server, sched schedule.Schedule, now time.Time) (*Plan, error) {
+	p := &Plan{
+		CreatedAt:  now,
+		ServerID:   server.ID,
+		ServerName: server.Name,
+		State:      stateOf(server),
+	}
+
+	// Requirement tiers are resolved to concrete server types now
+	targetTypes, reason, err := sched.Desired(now).Resolve(client, server)
+	if err != nil {
+		return nil, err
+	}
+	p.TargetTypes = targetTypes
+	p.Reason = reason
+
+	if !p.HasChanges() {
+		return p, nil
+	}
+
+	// Prices refer to the preferred server type of the tier
+	targetType, _, err := client.ServerType.GetByName(context.Background(), p.TargetType())
+	if err != nil {
+		return nil, err
+	}
+	if targetType == nil {
+		return nil, fmt.Errorf("server type %s not found", p.TargetType())
+	}
+
+	location := pricing.ServerLocation(server)
+	p.PriceHourlyFrom, p.PriceMonthlyFrom, p.Currency, _ = pricing.ServerTypePrice(server.ServerType, location)
+	p.PriceHourlyTo, p.PriceMonthlyTo, _, _ = pricing.ServerTypePrice(targetType, location)
+
+	// A server which is already off does not go down
+	if server.Status == hcloud.ServerStatusRunning {
+		p.ExpectedDowntime = EstimatedDowntime
+	}
+
+	return p, nil
+}
+
+/* Preferred server type of the target tier */
+func (p *Plan) TargetType() string {
+	if len(p.TargetTypes) == 0 {
+		return ""
+	}
+	return p.TargetTypes[0]
+}
+
+/* Whether applying the plan changes the server type */
+func (p *Plan) HasChanges() bool {
+	return !tier.Tier{Names: p.TargetTypes}.Contains(p.State.ServerType)
+}
+
+/* Check that the server has not changed since the plan was produced */
+func (p *Plan) Verify(server *hcloud.Server) error {
+	if server.ID != p.ServerID {
+		return fmt.Errorf("the plan was produced for server %d, not %d", p.ServerID, server.ID)
+	}
+
+	current := stateOf(server)
+	if current != p.State {
+		return fmt.Errorf("the server state changed since the plan was produced (type %s → %s, status %s → %s, locked %t → %t)",
+			p.State.ServerType, current.ServerType,
+			p.State.Status, current.Status,
+			p.State.Locked, current.Locked,
+		)
+	}
+
+	return nil
+}
+
+/* Write the plan to a JSON file */
+func (p *Plan) Save(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+/* Read a plan from a JSON file */
+func Load(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var p Plan
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid plan file %s: %s", path, err.Error())
+	}
+	return &p, nil
+}
+
+func stateOf(server *hcloud.Server) ServerState {
+	return ServerState{
+		ServerType: server.ServerType.Name,
+		Status:     string(server.Status),
+		Locked:     server.Locked,
+	}
+}
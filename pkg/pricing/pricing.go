@@ -0,0 +1,42 @@
+package pricing
+
+import (
+	"strconv"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+)
+
+/* Gross hourly and monthly price of the server type in the given location */
+func ServerTypePrice(serverType *hcloud.ServerType, location string) (hourly float64, monthly float64, currency string, ok bool) {
+	if serverType == nil || len(serverType.Pricings) == 0 {
+		return 0, 0, "", false
+	}
+
+	// Fallback to the first location if the requested one is not listed
+	pricing := serverType.Pricings[0]
+	for _, p := range serverType.Pricings {
+		if p.Location != nil && p.Location.Name == location {
+			pricing = p
+			break
+		}
+	}
+
+	hourly, err := strconv.ParseFloat(pricing.Hourly.Gross, 64)
+	if err != nil {
+		return 0, 0, "", false
+	}
+	monthly, err = strconv.ParseFloat(pricing.Monthly.Gross, 64)
+	if err != nil {
+		return 0, 0, "", false
+	}
+
+	return hourly, monthly, pricing.Hourly.Currency, true
+}
+
+/* Location name of the server, empty if unknown */
+func ServerLocation(server *hcloud.Server) string {
+	if server.Datacenter == nil || server.Datacenter.Location == nil {
+		return ""
+	}
+	return server.Datacenter.Location.Name
+}
@@ -0,0 +1,165 @@
+package recommend
+
+import (
+	"math"
+	"time"
+
+	"github.com/jonamat/hetzner-rescaler/pkg/autoscale"
+)
+
+/* Hours in a month, as used by the Hetzner billing */
+const HoursPerMonth = 730
+
+/* Hours of a day, Stop excluded, Stop is above 24 for a period crossing midnight */
+type Period struct {
+	Start int
+	Stop  int
+}
+
+func (p Period) Hours() int {
+	return p.Stop - p.Start
+}
+
+func (p Period) Contains(hour int) bool {
+	return (hour >= p.Start && hour < p.Stop) || (hour+24 >= p.Start && hour+24 < p.Stop)
+}
+
+/* CPU usage profile of a server per weekday and hour */
+type Profile struct {
+	// Average CPU usage in percent, NaN if there are no samples
+	Usage [7][24]float64
+	// Days covered by the samples
+	Days int
+}
+
+/* Average the CPU samples per weekday and hour in the given location */
+func Analyze(points []autoscale.Point, location *time.Location) Profile {
+	var sums [7][24]float64
+	var counts [7][24]int
+	days := map[string]bool{}
+
+	for _, p := range points {
+		t := p.Time.In(location)
+		sums[t.Weekday()][t.Hour()] += p.Value
+		counts[t.Weekday()][t.Hour()]++
+		days[t.Format("2006-01-02")] = true
+	}
+
+	profile := Profile{Days: len(days)}
+	for day := range sums {
+		for hour := range sums[day] {
+			if counts[day][hour] == 0 {
+				profile.Usage[day][hour] = math.NaN()
+				continue
+			}
+			profile.Usage[day][hour] = sums[day][hour] / float64(counts[day][hour])
+		}
+	}
+	return profile
+}
+
+/* Longest run of hours of the weekday with a usage at or above the threshold, possibly crossing midnight */
+func (p Profile) BusyPeriod(day time.Weekday, threshold float64) (Period, bool) {
+	var busy [24]bool
+	for hour, usage := range p.Usage[day] {
+		busy[hour] = usage >= threshold
+	}
+	return longestRun(busy)
+}
+
+/* Shortest daily window covering the busy period of every weekday, possibly crossing midnight */
+func (p Profile) Window(threshold float64) (Period, bool) {
+	var covered, idle [24]bool
+	found := false
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		busy, ok := p.BusyPeriod(day, threshold)
+		if !ok {
+			continue
+		}
+		found = true
+		for hour := busy.Start; hour < busy.Stop; hour++ {
+			covered[hour%24] = true
+		}
+	}
+	if !found {
+		return Period{}, false
+	}
+
+	// The window is the rest of the day once the longest idle run is removed
+	for hour := range covered {
+		idle[hour] = !covered[hour]
+	}
+	gap, ok := longestRun(idle)
+	if !ok {
+		return Period{Start: 0, Stop: 24}, true
+	}
+	start := gap.Stop % 24
+	return Period{Start: start, Stop: start + 24 - gap.Hours()}, true
+}
+
+/* Longest run of set hours, searched around the clock so that a run crossing midnight is not cut */
+func longestRun(hours [24]bool) (Period, bool) {
+	first := -1
+	for hour, set := range hours {
+		if !set {
+			first = hour
+			break
+		}
+	}
+	if first == -1 {
+		return Period{Start: 0, Stop: 24}, true
+	}
+
+	// Start right after an unset hour, so that every run is seen whole
+	var best, current Period
+	for hour := first + 1; hour <= first+24; hour++ {
+		if hours[hour%24] {
+			if current.Hours() == 0 {
+				current = Period{Start: hour}
+			}
+			current.Stop = hour + 1
+			if current.Hours() > best.Hours() {
+				best = current
+			}
+			continue
+		}
+		current = Period{}
+	}
+	if best.Start >= 24 {
+		best.Start -= 24
+		best.Stop -= 24
+	}
+	return best, best.Hours() > 0
+}
+
+/* Highest hourly usage inside (or outside) the window over all the weekdays */
+func (p Profile) Peak(window Period, inside bool) float64 {
+	var peak float64
+	for day := range p.Usage {
+		for hour, usage := range p.Usage[day] {
+			if math.IsNaN(usage) || window.Contains(hour) != inside {
+				continue
+			}
+			peak = math.Max(peak, usage)
+		}
+	}
+	return peak
+}
+
+/* Cores needed to serve the usage, measured on a server with the given cores, at the target usage in percent */
+func Cores(usage float64, cores int, target float64) int {
+	needed := int(math.Ceil(usage / 100 * float64(cores) / (target / 100)))
+	if needed < 1 {
+		return 1
+	}
+	return needed
+}
+
+/* Monthly cost of a server spending hoursPerDay on a type and the rest of the day on another */
+func MonthlyCost(hourly float64, hoursPerDay int, otherHourly float64, monthlyCap float64) float64 {
+	cost := (hourly*float64(hoursPerDay) + otherHourly*float64(24-hoursPerDay)) / 24 * HoursPerMonth
+	if monthlyCap > 0 {
+		cost = math.Min(cost, monthlyCap)
+	}
+	return cost
+}
@@ -0,0 +1,77 @@
+package recommend
+
+import (
+	"testing"
+	"time"
+)
+
+/* Profile with the given usage on the busy hours of the weekdays and none otherwise */
+func busyProfile(usage float64, busy map[time.Weekday][]int) Profile {
+	var p Profile
+	for day, hours := range busy {
+		for _, hour := range hours {
+			p.Usage[day][hour] = usage
+		}
+	}
+	return p
+}
+
+func TestBusyPeriod(t *testing.T) {
+	tests := []struct {
+		name   string
+		hours  []int
+		want   Period
+		wantOk bool
+	}{
+		{name: "idle", hours: nil, wantOk: false},
+		{name: "within the day", hours: []int{9, 10, 11, 14}, want: Period{Start: 9, Stop: 12}, wantOk: true},
+		{name: "crossing midnight", hours: []int{0, 1, 12, 22, 23}, want: Period{Start: 22, Stop: 26}, wantOk: true},
+		{name: "all day", hours: []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23}, want: Period{Start: 0, Stop: 24}, wantOk: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := busyProfile(80, map[time.Weekday][]int{time.Monday: tt.hours})
+			got, ok := p.BusyPeriod(time.Monday, 50)
+			if ok != tt.wantOk {
+				t.Fatalf("got ok %t, want %t", ok, tt.wantOk)
+			}
+			if ok && got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindowCrossingMidnight(t *testing.T) {
+	p := busyProfile(80, map[time.Weekday][]int{
+		time.Friday:   {22, 23, 0, 1},
+		time.Saturday: {23, 0, 1, 2},
+	})
+	p.Usage[time.Monday][10] = 40
+
+	window, ok := p.Window(50)
+	if !ok {
+		t.Fatal("no window found")
+	}
+	if want := (Period{Start: 22, Stop: 27}); window != want {
+		t.Fatalf("got %+v, want %+v", window, want)
+	}
+	if window.Hours() != 5 {
+		t.Errorf("got %d hours, want 5", window.Hours())
+	}
+
+	for hour, want := range map[int]bool{21: false, 22: true, 0: true, 2: true, 3: false, 10: false} {
+		if got := window.Contains(hour); got != want {
+			t.Errorf("Contains(%d) = %t, want %t", hour, got, want)
+		}
+	}
+
+	// The base type is sized from the hours left outside the window
+	if peak := p.Peak(window, false); peak != 40 {
+		t.Errorf("got a peak of %.0f outside the window, want 40 from monday 10:00", peak)
+	}
+	if peak := p.Peak(window, true); peak != 80 {
+		t.Errorf("got a peak of %.0f inside the window, want 80", peak)
+	}
+}
@@ -0,0 +1,171 @@
+package rescaler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jonamat/hetzner-rescaler/pkg/logger"
+)
+
+/* Progress of a rescale, saved before each phase so a crash can be recovered */
+type Checkpoint struct {
+	ServerID    int      `json:"server_id"`
+	FromType    string   `json:"from_type"`
+	TargetTypes []string `json:"target_types"`
+	// Server type applied, empty until the change type phase succeeded
+	ToType string    `json:"to_type,omitempty"`
+	Phase  string    `json:"phase"`
+	Start  time.Time `json:"start"`
+}
+
+func checkpointPath(dir string, serverID int) string {
+	return filepath.Join(dir, fmt.Sprintf("rescale-%d.checkpoint.json", serverID))
+}
+
+/* Save the checkpoint of the rescale before the given phase, if enabled */
+func (r *run) checkpoint(phase string) {
+	if r.opts.CheckpointDir == "" || r.opts.DryRun {
+		return
+	}
+
+	data, err := json.MarshalIndent(Checkpoint{
+		ServerID:    r.server.ID,
+		FromType:    r.result.FromType,
+		TargetTypes: r.targets,
+		ToType:      r.result.ToType,
+		Phase:       phase,
+		Start:       r.result.Start,
+	}, "", "  ")
+	if err == nil {
+		path := checkpointPath(r.opts.CheckpointDir, r.server.ID)
+		if err = os.WriteFile(path+".tmp", data, 0600); err == nil {
+			err = os.Rename(path+".tmp", path)
+		}
+	}
+	if err != nil {
+		r.log.Errorf("Error while saving the rescale checkpoint: %s", err.Error())
+	}
+}
+
+/* Remove the checkpoint once the server is running again */
+func (r *run) clearCheckpoint() {
+	if r.opts.CheckpointDir == "" || r.opts.DryRun {
+		return
+	}
+	err := os.Remove(checkpointPath(r.opts.CheckpointDir, r.server.ID))
+	if err != nil && !os.IsNotExist(err) {
+		r.log.Errorf("Error while removing the rescale checkpoint: %s", err.Error())
+	}
+}
+
+/* Checkpoint of an interrupted rescale of the server, nil if there is none */
+func LoadCheckpoint(dir string, serverID int) (*Checkpoint, error) {
+	data, err := os.ReadFile(checkpointPath(dir, serverID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var c Checkpoint
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+/* Finish or roll back a rescale interrupted by a crash, so the server ends up running. The result is nil if no rescale was interrupted. */
+func Recover(client *hcloud.Client, server *hcloud.Server, opts Options) (*Result, error) {
+	if opts.CheckpointDir == "" {
+		return nil, nil
+	}
+	c, err := LoadCheckpoint(opts.CheckpointDir, server.ID)
+	if err != nil || c == nil {
+		return nil, err
+	}
+
+	r := &run{
+		client:  client,
+		server:  server,
+		opts:    opts,
+		targets: c.TargetTypes,
+		result: &Result{
+			ServerID: server.ID,
+			FromType: c.FromType,
+			Start:    time.Now(),
+			DryRun:   opts.DryRun,
+		},
+		log: serverLogger(server).With(logger.Fields{logger.FieldFromType: c.FromType}),
+	}
+	r.log.Warnf("Found a rescale from %s to %s interrupted during the %s phase, recovering...", c.FromType, strings.Join(c.TargetTypes, ", "), c.Phase)
+	r.emit(Event{Type: EventRescaleStarted, ServerType: strings.Join(c.TargetTypes, ",")})
+
+	err = r.recover()
+	r.result.finish(err)
+	if err == nil {
+		r.clearCheckpoint()
+	}
+
+	r.emit(Event{
+		Type:       EventRescaleFinished,
+		ServerType: r.result.ToType,
+		Duration:   r.result.End.Sub(r.result.Start),
+		Err:        err,
+		Result:     r.result,
+	})
+	return r.result, err
+}
+
+func (r *run) recover() error {
+	// Let the action running at the time of the crash complete
+	server, err := r.waitSettled()
+	if err != nil {
+		return err
+	}
+	r.server = server
+
+	r.result.ToType = server.ServerType.Name
+	r.log = r.log.With(logger.Fields{logger.FieldToType: server.ServerType.Name})
+	if server.ServerType.Name == r.result.FromType {
+		r.log.Infof("The server is still of type %s, the rescale is rolled back", server.ServerType.Name)
+	} else {
+		r.log.Infof("The server is already of type %s, the rescale is finished", server.ServerType.Name)
+	}
+
+	if server.Status == hcloud.ServerStatusRunning {
+		r.log.Infof("Server is running")
+		return nil
+	}
+
+	r.log.Infof("Starting the server...")
+	if err := r.powerOn(); err != nil {
+		return err
+	}
+	r.log.Infof("Server is running")
+	return nil
+}
+
+/* Fetch the server until no action is running on it and it is either running or off */
+func (r *run) waitSettled() (*hcloud.Server, error) {
+	for {
+		server, _, err := r.client.Server.GetByID(context.Background(), r.server.ID)
+		if err != nil {
+			return nil, err
+		}
+		if server == nil {
+			return nil, fmt.Errorf("server %d not found", r.server.ID)
+		}
+
+		if !server.Locked && (server.Status == hcloud.ServerStatusRunning || server.Status == hcloud.ServerStatusOff) {
+			return server, nil
+		}
+		time.Sleep(time.Second * 5)
+	}
+}
@@ -0,0 +1,63 @@
+package rescaler
+
+import (
+	"io"
+	"time"
+
+	"github.com/jonamat/hetzner-rescaler/pkg/logger"
+)
+
+/* Kind of progress event emitted during a rescale */
+type EventType string
+
+const (
+	EventRescaleStarted  EventType = "rescale_started"
+	EventRescaleFinished EventType = "rescale_finished"
+	EventPhaseStarted    EventType = "phase_started"
+	EventPhaseFinished   EventType = "phase_finished"
+	EventActionProgress  EventType = "action_progress"
+	EventRetryScheduled  EventType = "retry_scheduled"
+)
+
+/* Progress event of a rescale, fields not relevant to the event type are left empty */
+type Event struct {
+	Type     EventType
+	Time     time.Time
+	ServerID int
+	// Phase the event belongs to, empty for rescale events
+	Phase    string
+	ActionID int
+	// Progress of the action in percent
+	Progress int
+	// Server type the event refers to
+	ServerType string
+	// Duration of a finished phase or rescale, or delay before a retry
+	Duration time.Duration
+	Err      error
+	// Result of the rescale, set on EventRescaleFinished
+	Result *Result
+}
+
+/* Receives the progress events of a rescale */
+type Observer interface {
+	OnEvent(Event)
+}
+
+/* Adapter to use an ordinary function as Observer */
+type ObserverFunc func(Event)
+
+func (f ObserverFunc) OnEvent(e Event) {
+	f(e)
+}
+
+/* Observer forwarding the events to a channel, blocking if the channel is full */
+func ChannelObserver(ch chan<- Event) Observer {
+	return ObserverFunc(func(e Event) {
+		ch <- e
+	})
+}
+
+/* Redirect the log output of the package, eg. io.Discard to silence it */
+func SetOutput(w io.Writer) {
+	sublogger = logger.Default().WithOutput(w)
+}
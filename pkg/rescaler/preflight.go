@@ -0,0 +1,107 @@
+package rescaler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+)
+
+/* Options of the checks made before touching a server */
+type PreflightOptions struct {
+	// Protections refusing the rescale when enabled on the server, "delete" and/or "rebuild"
+	RefuseProtection []string
+	// Rescale even if the server is within its backup window
+	IgnoreBackupWindow bool
+}
+
+/* The server is not in a state where it can be rescaled */
+type PreflightError struct {
+	// The rescale can be retried later, otherwise it's refused
+	Postpone bool
+	Reasons  []string
+}
+
+func (e *PreflightError) Error() string {
+	if e.Postpone {
+		return "rescale postponed: " + strings.Join(e.Reasons, ", ")
+	}
+	return "rescale refused: " + strings.Join(e.Reasons, ", ")
+}
+
+/* Whether the error is a pre-flight failure that can be retried later */
+func IsPostponed(err error) bool {
+	var preflightErr *PreflightError
+	return errors.As(err, &preflightErr) && preflightErr.Postpone
+}
+
+/* Check that the server can be safely rescaled at the given time */
+func Preflight(server *hcloud.Server, now time.Time, opts PreflightOptions) error {
+	var refuse, postpone []string
+
+	if server.RescueEnabled {
+		refuse = append(refuse, "the server is in rescue mode")
+	}
+	if server.ISO != nil {
+		refuse = append(refuse, fmt.Sprintf("the ISO %s is attached to the server", server.ISO.Name))
+	}
+	for _, protection := range opts.RefuseProtection {
+		switch protection {
+		case "delete":
+			if server.Protection.Delete {
+				refuse = append(refuse, "the server has delete protection enabled")
+			}
+		case "rebuild":
+			if server.Protection.Rebuild {
+				refuse = append(refuse, "the server has rebuild protection enabled")
+			}
+		}
+	}
+
+	if server.Locked {
+		postpone = append(postpone, "the server is locked by another running action")
+	}
+	if !opts.IgnoreBackupWindow && inBackupWindow(server.BackupWindow, now) {
+		postpone = append(postpone, fmt.Sprintf("the server is within its backup window %s UTC", server.BackupWindow))
+	}
+
+	log := serverLogger(server)
+	for _, reason := range append(refuse, postpone...) {
+		log.Warnf("Pre-flight check failed: %s", reason)
+	}
+
+	// A refusal wins over a postponement, waiting would not help
+	if len(refuse) > 0 {
+		return &PreflightError{Reasons: append(refuse, postpone...)}
+	}
+	if len(postpone) > 0 {
+		return &PreflightError{Postpone: true, Reasons: postpone}
+	}
+
+	return nil
+}
+
+/* Whether the time is within a Hetzner backup window, eg. "22-02" in UTC hours */
+func inBackupWindow(window string, now time.Time) bool {
+	parts := strings.Split(window, "-")
+	if len(parts) != 2 {
+		return false
+	}
+	start, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+	end, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false
+	}
+
+	hour := now.UTC().Hour()
+	if start < end {
+		return hour >= start && hour < end
+	}
+	return hour >= start || hour < end
+}
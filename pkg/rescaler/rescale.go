@@ -2,86 +2,302 @@ package rescaler
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jonamat/hetzner-rescaler/pkg/logger"
 )
 
-/* Default logger but more fancy */
-var sublogger = log.New(
-	log.Writer(),
-	"▶ ",
-	log.Ldate|log.Ltime,
-)
+/* Logger of the package, see SetOutput */
+var sublogger = logger.Default()
 
-/* Rescale the provided server to the target machine type */
-func Rescale(client *hcloud.Client, server *hcloud.Server, targetServerName string) error {
-	// Server is already of the target server type
-	if server.ServerType.Name == targetServerName {
-		sublogger.Printf("Server is already of type %s, rescale skipped.\n", targetServerName)
-		return nil
+/* Options altering the behaviour of a rescale */
+type Options struct {
+	// Log the API calls that would be made without performing mutating requests
+	DryRun bool
+	// Checks made before touching the server
+	Preflight PreflightOptions
+	// Receives the progress events, optional
+	Observer Observer
+	// Directory where the progress is saved before each phase, see Recover, disabled if empty
+	CheckpointDir string
+}
+
+/* State of a single rescale */
+type run struct {
+	client  *hcloud.Client
+	server  *hcloud.Server
+	opts    Options
+	targets []string
+	result  *Result
+	log     *logger.Logger
+}
+
+/* Rescale the provided server to the first available target machine type, the result is returned even on failure */
+func Rescale(client *hcloud.Client, server *hcloud.Server, targetServerNames []string, opts Options) (*Result, error) {
+	r := &run{
+		client:  client,
+		server:  server,
+		opts:    opts,
+		targets: targetServerNames,
+		result: &Result{
+			ServerID: server.ID,
+			FromType: server.ServerType.Name,
+			Start:    time.Now(),
+			DryRun:   opts.DryRun,
+		},
+		log: serverLogger(server),
 	}
+	r.emit(Event{Type: EventRescaleStarted, ServerType: strings.Join(targetServerNames, ",")})
 
-	if server.Status == hcloud.ServerStatusRunning {
-		sublogger.Println("Shutting down the server...")
-		// Shutdown the server
-		action, _, err := client.Server.Shutdown(context.Background(), server)
-		if err != nil {
-			return err
+	err := r.rescale(targetServerNames)
+	r.result.finish(err)
+
+	// Keep the checkpoint if the server may have been left off
+	if err == nil || r.result.Running() {
+		r.clearCheckpoint()
+	}
+
+	r.emit(Event{
+		Type:       EventRescaleFinished,
+		ServerType: r.result.ToType,
+		Duration:   r.result.End.Sub(r.result.Start),
+		Err:        err,
+		Result:     r.result,
+	})
+	return r.result, err
+}
+
+func (r *run) rescale(targetServerNames []string) error {
+	if len(targetServerNames) == 0 {
+		return fmt.Errorf("no target server type provided")
+	}
+
+	// Server is already of one of the target server types
+	for _, name := range targetServerNames {
+		if r.server.ServerType.Name != name {
+			continue
+		}
+		r.result.ToType = name
+		if r.server.Status != hcloud.ServerStatusOff {
+			r.log.Infof("Server is already of type %s, rescale skipped", name)
+			r.result.Skipped = true
+			return nil
 		}
 
-		// Wait for the server to shut down
-		if err := pollAction(client, action); err != nil {
+		// A previous rescale may have failed to start the server on its new type
+		if err := Preflight(r.server, time.Now(), r.opts.Preflight); err != nil {
+			return err
+		}
+		r.log.Warnf("Server is already of type %s but off, starting it...", name)
+		if err := r.powerOn(); err != nil {
 			return err
 		}
+		r.log.Infof("Server is running")
+		return nil
+	}
 
-		// Wait for the hetzner provisioner to be updated
-		time.Sleep(time.Second * 30)
-		sublogger.Println("done.")
+	// Refuse or postpone if the server cannot be safely touched now
+	if err := Preflight(r.server, time.Now(), r.opts.Preflight); err != nil {
+		return err
 	}
 
-	// Rescale to top server type
-	sublogger.Printf("Rescaling server to type %s...\n", targetServerName)
-	action, _, err := client.Server.ChangeType(context.Background(), server, hcloud.ServerChangeTypeOpts{
-		UpgradeDisk: false,
-		ServerType: &hcloud.ServerType{
-			Name: targetServerName,
-		},
-	})
+	if r.server.Status == hcloud.ServerStatusRunning {
+		r.log.Infof("Shutting down the server...")
+		if err := r.shutdown(); err != nil {
+			return err
+		}
+		r.log.Infof("Server is off")
+	}
+
+	// Rescale to the first server type that can be provided
+	r.begin(PhaseChangeType)
+	appliedServerName, err := r.changeType(targetServerNames)
+	r.end(err)
 	if err != nil {
+		// Do not leave the server off, bring it back on its previous type
+		r.log.Warnf("Rescale failed, starting the server on its previous type...")
+		if err := r.powerOn(); err != nil {
+			r.log.Errorf("Error while starting the server: %s", err.Error())
+		}
 		return err
 	}
+	r.result.ToType = appliedServerName
+	r.log = r.log.With(logger.Fields{logger.FieldToType: appliedServerName})
 
-	// Wait for the server to be rescaled
-	if err := pollAction(client, action); err != nil {
+	// Start the server
+	r.log.Infof("Starting the server...")
+	if err := r.powerOn(); err != nil {
 		return err
 	}
-	sublogger.Println("done.")
+	r.log.Infof("Server is running")
 
-	// Start the server
-	sublogger.Println("Starting the server...")
-	action, _, err = client.Server.Poweron(context.Background(), server)
+	return nil
+}
+
+/* Shutdown the server and wait until it's off */
+func (r *run) shutdown() error {
+	r.begin(PhaseShutdown)
+	if r.opts.DryRun {
+		r.log.Infof("[dry-run] POST /servers/%d/actions/shutdown", r.server.ID)
+		r.end(nil)
+		return nil
+	}
+
+	action, _, err := r.client.Server.Shutdown(context.Background(), r.server)
+	if err == nil {
+		err = r.pollAction(action)
+	}
+	r.end(err)
 	if err != nil {
 		return err
 	}
 
-	// Wait for the server to be started
-	if err := pollAction(client, action); err != nil {
+	// Wait for the server to be reported off
+	r.begin(PhaseWaitOff)
+	err = r.waitStatus(hcloud.ServerStatusOff)
+	if err == nil {
+		// Wait for the hetzner provisioner to be updated
+		time.Sleep(time.Second * 30)
+	}
+	r.end(err)
+	return err
+}
+
+/* Try the candidate server types in order, moving to the next one on availability errors */
+func (r *run) changeType(targetServerNames []string) (string, error) {
+	var errs []string
+
+	for i, name := range targetServerNames {
+		log := r.log.With(logger.Fields{logger.FieldToType: name})
+		log.Infof("Rescaling server to type %s...", name)
+		if r.opts.DryRun {
+			log.Infof("[dry-run] POST /servers/%d/actions/change_type {\"server_type\":\"%s\",\"upgrade_disk\":false}", r.server.ID, name)
+			return name, nil
+		}
+
+		action, _, err := r.client.Server.ChangeType(context.Background(), r.server, hcloud.ServerChangeTypeOpts{
+			UpgradeDisk: false,
+			ServerType: &hcloud.ServerType{
+				Name: name,
+			},
+		})
+
+		// Wait for the server to be rescaled
+		if err == nil {
+			err = r.pollAction(action)
+		}
+		if err == nil {
+			log.Infof("Server rescaled to type %s", name)
+			return name, nil
+		}
+
+		if !isUnavailable(err) {
+			return "", err
+		}
+
+		errs = append(errs, fmt.Sprintf("%s: %s", name, err.Error()))
+		if i < len(targetServerNames)-1 {
+			log.Warnf("Server type %s is not available (%s), trying %s", name, err.Error(), targetServerNames[i+1])
+			r.emit(Event{Type: EventRetryScheduled, Phase: PhaseChangeType, ServerType: targetServerNames[i+1], Err: err})
+		}
+	}
+
+	return "", fmt.Errorf("none of the server types is available: %s", strings.Join(errs, "; "))
+}
+
+/* Power on the server and wait until it's running */
+func (r *run) powerOn() error {
+	r.begin(PhasePowerOn)
+	if r.opts.DryRun {
+		r.log.Infof("[dry-run] POST /servers/%d/actions/poweron", r.server.ID)
+		r.end(nil)
+		return nil
+	}
+
+	action, _, err := r.client.Server.Poweron(context.Background(), r.server)
+	if err == nil {
+		err = r.pollAction(action)
+	}
+	r.end(err)
+	if err != nil {
 		return err
 	}
-	sublogger.Println("done.")
 
-	return nil
+	// Wait for the server to be reported running
+	r.begin(PhaseHealthCheck)
+	err = r.waitStatus(hcloud.ServerStatusRunning)
+	r.end(err)
+	return err
+}
+
+/* Open a new phase */
+func (r *run) begin(phase string) {
+	r.checkpoint(phase)
+	r.result.begin(phase)
+	r.log.With(logger.Fields{logger.FieldPhase: phase}).Debugf("Phase started")
+	r.emit(Event{Type: EventPhaseStarted, Phase: phase})
+}
+
+/* Close the current phase */
+func (r *run) end(err error) {
+	r.result.end(err)
+	p := r.result.Phases[len(r.result.Phases)-1]
+	r.log.With(logger.Fields{logger.FieldPhase: p.Name}).Debugf("Phase finished in %s", p.Duration().Round(time.Millisecond))
+	r.emit(Event{Type: EventPhaseFinished, Phase: p.Name, Duration: p.Duration(), Err: err})
+}
+
+/* Send an event to the observer, if any */
+func (r *run) emit(e Event) {
+	if r.opts.Observer == nil {
+		return
+	}
+
+	e.Time = time.Now()
+	e.ServerID = r.server.ID
+	if e.Type == EventActionProgress && len(r.result.Phases) > 0 {
+		e.Phase = r.result.Phases[len(r.result.Phases)-1].Name
+	}
+	r.opts.Observer.OnEvent(e)
+}
+
+/* Logger with the fields identifying the server */
+func serverLogger(server *hcloud.Server) *logger.Logger {
+	fields := logger.Fields{logger.FieldServerID: server.ID, logger.FieldServerName: server.Name}
+	if server.ServerType != nil {
+		fields[logger.FieldFromType] = server.ServerType.Name
+	}
+	return sublogger.With(fields)
+}
+
+/* Whether the error means the server type cannot be provided right now */
+func isUnavailable(err error) bool {
+	if hcloud.IsError(err, hcloud.ErrorCodeResourceUnavailable) ||
+		hcloud.IsError(err, hcloud.ErrorCodePlacementError) {
+		return true
+	}
+
+	if actionErr, ok := err.(hcloud.ActionError); ok {
+		return actionErr.Code == string(hcloud.ErrorCodeResourceUnavailable) ||
+			actionErr.Code == string(hcloud.ErrorCodePlacementError)
+	}
+
+	return false
 }
 
 /* Fetch the status of the action until it's completed */
-func pollAction(client *hcloud.Client, action *hcloud.Action) error {
+func (r *run) pollAction(action *hcloud.Action) error {
+	r.result.action(action.ID)
+
 	for {
-		_action, _, err := client.Action.GetByID(context.Background(), action.ID)
+		_action, _, err := r.client.Action.GetByID(context.Background(), action.ID)
 		if err != nil {
 			return err
 		}
+		r.log.With(logger.Fields{logger.FieldActionID: _action.ID}).Debugf("Action %s at %d%%", _action.Command, _action.Progress)
+		r.emit(Event{Type: EventActionProgress, ActionID: _action.ID, Progress: _action.Progress})
 
 		switch _action.Status {
 		case hcloud.ActionStatusError:
@@ -94,3 +310,21 @@ func pollAction(client *hcloud.Client, action *hcloud.Action) error {
 		}
 	}
 }
+
+/* Fetch the server until it reaches the given status */
+func (r *run) waitStatus(status hcloud.ServerStatus) error {
+	for {
+		_server, _, err := r.client.Server.GetByID(context.Background(), r.server.ID)
+		if err != nil {
+			return err
+		}
+		if _server == nil {
+			return fmt.Errorf("server %d not found", r.server.ID)
+		}
+
+		if _server.Status == status {
+			return nil
+		}
+		time.Sleep(time.Second * 5)
+	}
+}
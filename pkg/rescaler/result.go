@@ -0,0 +1,157 @@
+package rescaler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+/* Phases of a rescale */
+const (
+	PhaseShutdown    = "shutdown"
+	PhaseWaitOff     = "wait_off"
+	PhaseChangeType  = "change_type"
+	PhasePowerOn     = "power_on"
+	PhaseHealthCheck = "health_check"
+)
+
+/* Outcome of a rescale */
+const (
+	OutcomeSuccess   = "success"
+	OutcomeSkipped   = "skipped"
+	OutcomePostponed = "postponed"
+	OutcomeRefused   = "refused"
+	OutcomeFailed    = "failed"
+)
+
+/* Timing of a single phase of the rescale */
+type Phase struct {
+	Name      string    `json:"name"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	ActionIDs []int     `json:"action_ids,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+/* Duration of the phase */
+func (p Phase) Duration() time.Duration {
+	return p.End.Sub(p.Start)
+}
+
+/* Outcome of a rescale */
+type Result struct {
+	ServerID int       `json:"server_id"`
+	FromType string    `json:"from_type"`
+	ToType   string    `json:"to_type"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Phases   []Phase   `json:"phases"`
+	// Time the server was not running because of the rescale
+	Downtime time.Duration `json:"downtime"`
+	// The server was already of a target type
+	Skipped bool `json:"skipped"`
+	DryRun  bool `json:"dry_run"`
+	// One of the Outcome constants
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+}
+
+/* IDs of all the actions involved in the rescale */
+func (r *Result) ActionIDs() []int {
+	var ids []int
+	for _, p := range r.Phases {
+		ids = append(ids, p.ActionIDs...)
+	}
+	return ids
+}
+
+/* Whether the server was left running, either untouched or started again */
+func (r *Result) Running() bool {
+	if len(r.Phases) == 0 {
+		return true
+	}
+	last := r.Phases[len(r.Phases)-1]
+	return last.Name == PhaseHealthCheck && last.Error == ""
+}
+
+/* Human readable summary of the rescale */
+func (r *Result) String() string {
+	if r.Skipped {
+		return fmt.Sprintf("server already of type %s, rescale skipped", r.ToType)
+	}
+
+	var phases []string
+	for _, p := range r.Phases {
+		phases = append(phases, fmt.Sprintf("%s %s", p.Name, p.Duration().Round(time.Second)))
+	}
+
+	return fmt.Sprintf("%s → %s in %s, downtime %s (%s), actions %v",
+		r.FromType,
+		r.ToType,
+		r.End.Sub(r.Start).Round(time.Second),
+		r.Downtime.Round(time.Second),
+		strings.Join(phases, ", "),
+		r.ActionIDs(),
+	)
+}
+
+/* Write the result to a JSON file */
+func (r *Result) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+/* Open a new phase */
+func (r *Result) begin(name string) {
+	r.Phases = append(r.Phases, Phase{Name: name, Start: time.Now()})
+}
+
+/* Close the current phase */
+func (r *Result) end(err error) {
+	p := &r.Phases[len(r.Phases)-1]
+	p.End = time.Now()
+	if err != nil {
+		p.Error = err.Error()
+	}
+}
+
+/* Record an action of the current phase */
+func (r *Result) action(id int) {
+	p := &r.Phases[len(r.Phases)-1]
+	p.ActionIDs = append(p.ActionIDs, id)
+}
+
+/* Close the result, computing the downtime from the first shutdown to the end of the health check */
+func (r *Result) finish(err error) {
+	r.End = time.Now()
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	var preflightErr *PreflightError
+	switch {
+	case IsPostponed(err):
+		r.Outcome = OutcomePostponed
+	case errors.As(err, &preflightErr):
+		r.Outcome = OutcomeRefused
+	case err != nil:
+		r.Outcome = OutcomeFailed
+	case r.Skipped:
+		r.Outcome = OutcomeSkipped
+	default:
+		r.Outcome = OutcomeSuccess
+	}
+
+	for _, p := range r.Phases {
+		if p.Name == PhaseShutdown {
+			r.Downtime = r.End.Sub(p.Start)
+			break
+		}
+	}
+}
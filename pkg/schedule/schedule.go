@@ -0,0 +1,129 @@
+package schedule
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jonamat/hetzner-rescaler/pkg/tier"
+)
+
+/* Daily schedule: top tier between HourStart and HourStop, base tier otherwise */
+type Schedule struct {
+	HourStart string
+	HourStop  string
+	Top       tier.Tier
+	Base      tier.Tier
+}
+
+/* Parse a 24h format hour into minutes since midnight */
+func parseHour(hour string) (int, error) {
+	t, err := time.Parse("15:04", hour)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hour %q, use a 24h format like 20:30", hour)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+/* Check that the schedule hours are valid and distinct, and the tiers do not overlap */
+func (s Schedule) Validate() error {
+	start, err := parseHour(s.HourStart)
+	if err != nil {
+		return err
+	}
+	stop, err := parseHour(s.HourStop)
+	if err != nil {
+		return err
+	}
+	if start == stop {
+		return fmt.Errorf("start and stop hours must be different")
+	}
+
+	for _, name := range s.Top.Names {
+		if s.Base.Contains(name) {
+			return fmt.Errorf("server type %s cannot be both a top and a base server type", name)
+		}
+	}
+	return nil
+}
+
+/* Human readable changes from the old schedule to this one, empty if they are the same */
+func (s Schedule) Diff(old Schedule) []string {
+	var changes []string
+	add := func(key, from, to string) {
+		if from != to {
+			changes = append(changes, fmt.Sprintf("%s %s → %s", key, from, to))
+		}
+	}
+	add("hour start", old.HourStart, s.HourStart)
+	add("hour stop", old.HourStop, s.HourStop)
+	add("top tier", old.Top.String(), s.Top.String())
+	add("base tier", old.Base.String(), s.Base.String())
+	return changes
+}
+
+/* Whether the given time is between HourStart and HourStop */
+func (s Schedule) Active(t time.Time) bool {
+	start, _ := parseHour(s.HourStart)
+	stop, _ := parseHour(s.HourStop)
+	now := t.Hour()*60 + t.Minute()
+
+	// The top window may span midnight, eg. 22:00 -> 06:00
+	if start < stop {
+		return now >= start && now < stop
+	}
+	return now >= start || now < stop
+}
+
+/* Tier the schedule wants at the given time */
+func (s Schedule) Desired(t time.Time) tier.Tier {
+	if s.Active(t) {
+		return s.Top
+	}
+	return s.Base
+}
+
+/* Time and target tier of the next transition after the given time */
+func (s Schedule) Next(t time.Time) (time.Time, tier.Tier) {
+	start, _ := parseHour(s.HourStart)
+	stop, _ := parseHour(s.HourStop)
+
+	nextStart := nextOccurrence(t, start)
+	nextStop := nextOccurrence(t, stop)
+	if nextStart.Before(nextStop) {
+		return nextStart, s.Top
+	}
+	return nextStop, s.Base
+}
+
+/* Whether a transition falls between from (excluded) and to (included) and if it is to the top tier, the last one wins if there are several */
+func (s Schedule) Due(from, to time.Time) (top bool, ok bool) {
+	start, _ := parseHour(s.HourStart)
+	stop, _ := parseHour(s.HourStop)
+
+	lastStart := lastOccurrence(to, start)
+	lastStop := lastOccurrence(to, stop)
+	if lastStart.After(lastStop) {
+		return true, lastStart.After(from)
+	}
+	return false, lastStop.After(from)
+}
+
+/* Last time at or before t at the given minute of the day */
+func lastOccurrence(t time.Time, minutes int) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	last := day.Add(time.Duration(minutes) * time.Minute)
+	if last.After(t) {
+		last = last.AddDate(0, 0, -1)
+	}
+	return last
+}
+
+/* First time strictly after t at the given minute of the day */
+func nextOccurrence(t time.Time, minutes int) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	next := day.Add(time.Duration(minutes) * time.Minute)
+	if !next.After(t) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
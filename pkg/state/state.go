@@ -0,0 +1,174 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jonamat/hetzner-rescaler/pkg/filelock"
+)
+
+/* Operations on the schedule which survive restarts */
+type State struct {
+	Paused   bool      `json:"paused"`
+	PausedAt time.Time `json:"paused_at,omitempty"`
+	// Automatic resume, zero if the pause lasts until resumed
+	ResumeAt time.Time `json:"resume_at,omitempty"`
+	// Skip the next scheduled transition
+	SkipNext bool `json:"skip_next"`
+	// Temporary upgrade, nil if none
+	Boost *Boost `json:"boost,omitempty"`
+}
+
+/* Temporary upgrade, the schedule is applied again once it expires */
+type Boost struct {
+	ServerTypes []string  `json:"server_types"`
+	Start       time.Time `json:"start"`
+	Until       time.Time `json:"until"`
+	// The upgrade is left to the running daemon, which holds the lease of the server
+	Delegated bool `json:"delegated,omitempty"`
+}
+
+/* Whether the scheduling is paused at the given time */
+func (s State) IsPaused(now time.Time) bool {
+	return s.Paused && (s.ResumeAt.IsZero() || now.Before(s.ResumeAt))
+}
+
+/* Whether a boost is running at the given time */
+func (s State) Boosted(now time.Time) bool {
+	return s.Boost != nil && now.Before(s.Boost.Until)
+}
+
+/* Clear the pause, keeping the other operations */
+func (s *State) Resume() {
+	s.Paused = false
+	s.PausedAt = time.Time{}
+	s.ResumeAt = time.Time{}
+}
+
+/* JSON file holding the state, shared by the daemon and the commands */
+type Store struct {
+	path string
+	mu   sync.Mutex
+	// Used when there is no file
+	memory State
+}
+
+/* Store backed by the state.json file in the given directory, in memory if dir is empty */
+func Open(dir string) *Store {
+	if dir == "" {
+		return &Store{}
+	}
+	return &Store{path: filepath.Join(dir, "state.json")}
+}
+
+/* Read the state, the zero state if it was never saved */
+func (s *Store) Load() (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+/* Change the state and save it, the file lock keeps the daemon and the commands from overwriting each other */
+func (s *Store) Update(change func(*State)) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.lock()
+	if err != nil {
+		return State{}, fmt.Errorf("error while locking the state: %s", err.Error())
+	}
+	defer unlock()
+
+	st, err := s.load()
+	if err != nil {
+		return st, err
+	}
+	change(&st)
+	return st, s.save(st)
+}
+
+/* Clear the boost if it is still the given one, only the caller which cleared it applies the schedule again */
+func (s *Store) EndBoost(boost Boost) (bool, error) {
+	ended := false
+	_, err := s.Update(func(st *State) {
+		if st.Boost != nil && st.Boost.Start.Equal(boost.Start) {
+			st.Boost = nil
+			ended = true
+		}
+	})
+	return ended && err == nil, err
+}
+
+/* Take the state.json.lock file lock across processes, the returned func releases it */
+func (s *Store) lock() (func(), error) {
+	if s.path == "" {
+		return func() {}, nil
+	}
+	return filelock.Lock(s.path + ".lock")
+}
+
+func (s *Store) load() (State, error) {
+	if s.path == "" {
+		return s.memory, nil
+	}
+
+	var st State
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+	err = json.Unmarshal(data, &st)
+	return st, err
+}
+
+/* Write to a temporary file first, so readers never see a partial state */
+func (s *Store) save(st State) error {
+	if s.path == "" {
+		s.memory = st
+		return nil
+	}
+
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+/* Parse the end of a pause, either a duration ("2h"), a time of day ("15:04") or a RFC 3339 date */
+func ResumeTime(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		if d <= 0 {
+			return time.Time{}, fmt.Errorf("the pause duration must be positive")
+		}
+		return now.Add(d), nil
+	}
+
+	if t, err := time.ParseInLocation("15:04", value, now.Location()); err == nil {
+		resume := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+		if !resume.After(now) {
+			resume = resume.AddDate(0, 0, 1)
+		}
+		return resume, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid resume time %q, expected a duration, HH:MM or a RFC 3339 date", value)
+	}
+	if !t.After(now) {
+		return time.Time{}, fmt.Errorf("the resume time %s is in the past", value)
+	}
+	return t, nil
+}
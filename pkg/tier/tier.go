@@ -0,0 +1,158 @@
+package tier
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jonamat/hetzner-rescaler/pkg/pricing"
+	"github.com/jonamat/hetzner-rescaler/pkg/validator"
+)
+
+/* Resources a server type must provide, zero values match anything */
+type Requirements struct {
+	MinCores  int
+	MinMemory float32
+	// "shared" or "dedicated"
+	CPUType string
+	// "x86" or "arm"
+	Architecture string
+}
+
+func (r Requirements) String() string {
+	var parts []string
+	if r.MinCores > 0 {
+		parts = append(parts, fmt.Sprintf("at least %d vCPU", r.MinCores))
+	}
+	if r.MinMemory > 0 {
+		parts = append(parts, fmt.Sprintf("at least %gGB memory", r.MinMemory))
+	}
+	if r.CPUType != "" {
+		parts = append(parts, r.CPUType+" CPU")
+	}
+	if r.Architecture != "" {
+		parts = append(parts, r.Architecture)
+	}
+	if len(parts) == 0 {
+		return "any server type"
+	}
+	return strings.Join(parts, ", ")
+}
+
+/* Check the requirement values */
+func (r Requirements) Validate() error {
+	if r.MinCores < 0 || r.MinMemory < 0 {
+		return fmt.Errorf("minimum cores and memory cannot be negative")
+	}
+	if r.CPUType != "" && r.CPUType != string(hcloud.CPUTypeShared) && r.CPUType != string(hcloud.CPUTypeDedicated) {
+		return fmt.Errorf("invalid cpu type %q, use shared or dedicated", r.CPUType)
+	}
+	if r.Architecture != "" && r.Architecture != "x86" && r.Architecture != "arm" {
+		return fmt.Errorf("invalid architecture %q, use x86 or arm", r.Architecture)
+	}
+	return nil
+}
+
+/* Server types of a tier: explicit names in order of preference, or requirements resolved at rescale time */
+type Tier struct {
+	Names        []string
+	Requirements *Requirements
+}
+
+func (t Tier) String() string {
+	if t.Requirements != nil {
+		return t.Requirements.String()
+	}
+	return strings.Join(t.Names, ", ")
+}
+
+/* Whether the tier has neither names nor requirements */
+func (t Tier) Empty() bool {
+	return len(t.Names) == 0 && t.Requirements == nil
+}
+
+/* Whether the server type is part of the tier, always false for requirement tiers */
+func (t Tier) Contains(name string) bool {
+	for _, n := range t.Names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+/* Server types of the tier for the server, in order of preference, with a description of the choice */
+func (t Tier) Resolve(client *hcloud.Client, server *hcloud.Server) ([]string, string, error) {
+	if t.Requirements == nil {
+		return t.Names, "", nil
+	}
+	return Select(client, server, *t.Requirements)
+}
+
+/* A server type matching the requirements */
+type candidate struct {
+	serverType *hcloud.ServerType
+	price      float64
+	currency   string
+}
+
+/* Cheapest compatible server type available in the server location and matching the requirements */
+func Select(client *hcloud.Client, server *hcloud.Server, req Requirements) ([]string, string, error) {
+	serverTypes, err := client.ServerType.All(context.Background())
+	if err != nil {
+		return nil, "", err
+	}
+
+	serverTypeValidator, err := validator.New(client, server)
+	if err != nil {
+		return nil, "", err
+	}
+
+	location := pricing.ServerLocation(server)
+	var candidates []candidate
+	for _, s := range serverTypes {
+		if s.Cores < req.MinCores || s.Memory < req.MinMemory {
+			continue
+		}
+		if req.CPUType != "" && string(s.CPUType) != req.CPUType {
+			continue
+		}
+		if req.Architecture != "" && serverTypeValidator.Architecture(s.Name) != req.Architecture {
+			continue
+		}
+		if serverTypeValidator.ServerType(s.Name) != nil {
+			continue
+		}
+
+		price, _, currency, ok := pricing.ServerTypePrice(s, location)
+		if !ok {
+			continue
+		}
+		candidates = append(candidates, candidate{serverType: s, price: price, currency: currency})
+	}
+
+	if len(candidates) == 0 {
+		return nil, "", fmt.Errorf("no server type available in %s matches %s", location, req)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].price < candidates[j].price
+	})
+
+	chosen := candidates[0]
+	reason := fmt.Sprintf("%s (%d vCPU, %gGB, %s CPU) at %.4f%s/h is the cheapest of %d server types matching %s",
+		chosen.serverType.Name,
+		chosen.serverType.Cores,
+		chosen.serverType.Memory,
+		chosen.serverType.CPUType,
+		chosen.price,
+		chosen.currency,
+		len(candidates),
+		req,
+	)
+
+	// Only the cheapest type is part of the tier, the others would match the other tier too
+	return []string{chosen.serverType.Name}, reason, nil
+}
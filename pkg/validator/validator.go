@@ -0,0 +1,167 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+)
+
+/* Server type attributes not exposed by hcloud-go */
+type apiServerType struct {
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	Disk         int    `json:"disk"`
+	Architecture string `json:"architecture"`
+	Deprecated   bool   `json:"deprecated"`
+	Deprecation  *struct {
+		UnavailableAfter string `json:"unavailable_after"`
+	} `json:"deprecation"`
+}
+
+type serverTypeListResponse struct {
+	ServerTypes []apiServerType `json:"server_types"`
+}
+
+type datacenterGetResponse struct {
+	Datacenter struct {
+		Name        string `json:"name"`
+		ServerTypes struct {
+			Available             []int `json:"available"`
+			AvailableForMigration []int `json:"available_for_migration"`
+		} `json:"server_types"`
+	} `json:"datacenter"`
+}
+
+/* Checks server types against a specific server */
+type Validator struct {
+	server     *hcloud.Server
+	datacenter string
+	types      map[string]apiServerType
+	available  map[int]bool
+}
+
+/* Fetch server types and datacenter availability for the server */
+func New(client *hcloud.Client, server *hcloud.Server) (*Validator, error) {
+	v := &Validator{
+		server:    server,
+		types:     map[string]apiServerType{},
+		available: map[int]bool{},
+	}
+
+	// All the server types, page by page
+	for page := 1; page != 0; {
+		var body serverTypeListResponse
+		req, err := client.NewRequest(context.Background(), "GET", fmt.Sprintf("/server_types?page=%d&per_page=50", page), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req, &body)
+		if err != nil {
+			return nil, fmt.Errorf("error while getting server types: %s", err.Error())
+		}
+		for _, t := range body.ServerTypes {
+			v.types[t.Name] = t
+		}
+
+		page = 0
+		if resp.Meta.Pagination != nil {
+			page = resp.Meta.Pagination.NextPage
+		}
+	}
+
+	// Server types the datacenter of the server can provide
+	if server.Datacenter != nil {
+		var body datacenterGetResponse
+		req, err := client.NewRequest(context.Background(), "GET", fmt.Sprintf("/datacenters/%d", server.Datacenter.ID), nil)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := client.Do(req, &body); err != nil {
+			return nil, fmt.Errorf("error while getting datacenter: %s", err.Error())
+		}
+
+		v.datacenter = body.Datacenter.Name
+		ids := body.Datacenter.ServerTypes.AvailableForMigration
+		if len(ids) == 0 {
+			ids = body.Datacenter.ServerTypes.Available
+		}
+		for _, id := range ids {
+			v.available[id] = true
+		}
+	}
+
+	return v, nil
+}
+
+/* Incompatibilities of the named server type with the server, and whether the datacenter can provide it now */
+func (v *Validator) check(name string) (problems []string, available bool, err error) {
+	target, ok := v.types[name]
+	if !ok {
+		return nil, false, fmt.Errorf("server type %s does not exist", name)
+	}
+
+	// The current type tells the architecture of the server
+	if current, ok := v.types[v.server.ServerType.Name]; ok && current.Architecture != "" && target.Architecture != "" {
+		if current.Architecture != target.Architecture {
+			problems = append(problems, fmt.Sprintf("architecture %s does not match the server architecture %s", target.Architecture, current.Architecture))
+		}
+	}
+
+	if target.Deprecated || target.Deprecation != nil {
+		problems = append(problems, "deprecated")
+	}
+
+	// The disk is never upgraded, so it must fit in the target type
+	if target.Disk < v.server.PrimaryDiskSize {
+		problems = append(problems, fmt.Sprintf("disk of %dGB is smaller than the server disk of %dGB", target.Disk, v.server.PrimaryDiskSize))
+	}
+
+	available = v.datacenter == "" || v.available[target.ID]
+	return problems, available, nil
+}
+
+/* Check that the server can be rescaled to the named server type */
+func (v *Validator) ServerType(name string) error {
+	problems, available, err := v.check(name)
+	if err != nil {
+		return err
+	}
+
+	if !available {
+		problems = append(problems, fmt.Sprintf("not available in datacenter %s", v.datacenter))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("server type %s is not compatible: %s", name, strings.Join(problems, ", "))
+	}
+
+	return nil
+}
+
+/* Check a list of fallback server types: all must be compatible, at least one must be available */
+func (v *Validator) Tier(names []string) error {
+	var anyAvailable bool
+	for _, name := range names {
+		problems, available, err := v.check(name)
+		if err != nil {
+			return err
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("server type %s is not compatible: %s", name, strings.Join(problems, ", "))
+		}
+		anyAvailable = anyAvailable || available
+	}
+
+	if !anyAvailable {
+		return fmt.Errorf("none of the server types %s is available in datacenter %s", strings.Join(names, ", "), v.datacenter)
+	}
+
+	return nil
+}
+
+/* CPU architecture of the named server type, empty if unknown */
+func (v *Validator) Architecture(name string) string {
+	return v.types[name].Architecture
+}